@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"GolangStore/config"
+	"GolangStore/database"
+	"GolangStore/models"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// The streams the commands read from and write to. They're replaced when
+// testing
+var (
+	Stdin  io.Reader = os.Stdin
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// Returned when the command line is wrong. The usage has been printed
+var ErrUsage = errors.New("invalid usage")
+
+// A subcommand of the store binary
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(cfg *config.Config, flags *flag.FlagSet, args []string) error
+}
+
+// The commands, in the order they're listed by the usage
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "Serve the application, the default command", serve},
+		{"migrate", "up|down [-steps n]|status", "Apply, undo or list the database migrations", migrate},
+		{"seed", "[-fixture file | -random [-seed n] [-users n] ...] [-print]", "Fill an empty database with the demo data, a fixture file or random data", seedCommand},
+		{"create-user", "[-role role] [-password password] username", "Create a user, reading the password from the standard input when not given", createUser},
+		{"import-products", "[-format csv|json] [file]", "Create or update the products listed in the file or the standard input", importProducts},
+		{"export-orders", "[-format csv|json] [-status status] [-output file]", "Write the orders to the file or the standard output", exportOrders},
+	}
+}
+
+// The storage the commands work with, backed by the database
+type Store struct {
+	DB         *sql.DB
+	Users      models.UserRepository
+	Articles   models.ArticleRepository
+	Products   models.ProductRepository
+	Categories models.CategoryRepository
+	Orders     models.OrderRepository
+}
+
+// Run the command named by the first argument, serve when there's none
+func Run(cfg *config.Config, args []string) error {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if Help([]string{name}) {
+		return nil
+	}
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		c := c
+		flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		flags.SetOutput(Stderr)
+		flags.Usage = func() {
+			fmt.Fprintf(Stderr, "Usage: GolangStore %s %s\n", c.name, c.args)
+			flags.PrintDefaults()
+		}
+		return c.run(cfg, flags, args)
+	}
+
+	printUsage(Stderr)
+	return ErrUsage
+}
+
+// Print the usage when the arguments ask for it, telling whether they did.
+// It doesn't need the configuration, so it's checked before reading it
+func Help(args []string) bool {
+	if len(args) == 0 || (args[0] != "help" && args[0] != "-h" && args[0] != "--help") {
+		return false
+	}
+	printUsage(Stdout)
+	return true
+}
+
+// List the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: GolangStore [command]\n\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nRun GolangStore <command> -h to list the flags of a command")
+}
+
+// Parse the flags of a command, which are printed when they're wrong
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return ErrUsage
+	}
+	return nil
+}
+
+// Print the usage of a command and fail
+func usageError(flags *flag.FlagSet) error {
+	flags.Usage()
+	return ErrUsage
+}
+
+// Connect to the database and run the function with the Postgres
+// repositories. SIGINT and SIGTERM cancel the context given to it
+func withStore(cfg *config.Config, f func(ctx context.Context, store *Store) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models.QueryTimeout = cfg.Database.QueryTimeout
+	return f(ctx, &Store{
+		DB:         db,
+		Users:      models.NewPostgresUserRepository(db),
+		Articles:   models.NewPostgresArticleRepository(db),
+		Products:   models.NewPostgresProductRepository(db),
+		Categories: models.NewPostgresCategoryRepository(db),
+		Orders:     models.NewPostgresOrderRepository(db),
+	})
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"GolangStore/config"
+	"GolangStore/database"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+)
+
+// Apply, undo or list the migrations of the database
+func migrate(cfg *config.Config, flags *flag.FlagSet, args []string) error {
+	steps := flags.Int("steps", 1, "how many migrations down undoes")
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		return usageError(flags)
+	}
+	action := args[0]
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return errors.New("-steps must be at least 1")
+	}
+
+	return withStore(cfg, func(ctx context.Context, store *Store) error {
+		switch action {
+		case "up":
+			applied, err := database.MigrateUp(ctx, store.DB)
+			for _, m := range applied {
+				fmt.Fprintf(Stdout, "Applied %d_%s\n", m.Version, m.Name)
+			}
+			if err == nil && len(applied) == 0 {
+				fmt.Fprintln(Stdout, "The database is up to date")
+			}
+			return err
+		case "down":
+			reverted, err := database.MigrateDown(ctx, store.DB, *steps)
+			for _, m := range reverted {
+				fmt.Fprintf(Stdout, "Reverted %d_%s\n", m.Version, m.Name)
+			}
+			return err
+		}
+
+		statuses, err := database.Status(ctx, store.DB)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	})
+}
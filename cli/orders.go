@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"GolangStore/config"
+	"GolangStore/models"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Write the orders to a file or the standard output
+func exportOrders(cfg *config.Config, flags *flag.FlagSet, args []string) error {
+	format := flags.String("format", "csv", "csv, without the items, or json")
+	status := flags.String("status", "", "only export the orders having this status")
+	output := flags.String("output", "", "the file written, the standard output by default")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	} else if flags.NArg() > 0 || (*format != "csv" && *format != "json") {
+		return usageError(flags)
+	}
+
+	return withStore(cfg, func(ctx context.Context, store *Store) error {
+		if *output == "" || *output == "-" {
+			_, err := ExportOrders(ctx, store.Orders, Stdout, *format, *status)
+			return err
+		}
+
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		count, err := ExportOrders(ctx, store.Orders, f, *format, *status)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		fmt.Fprintf(Stdout, "Exported %d orders to %s\n", count, *output)
+		return f.Close()
+	})
+}
+
+// Write the orders, most recent first, as CSV or JSON and return how many
+// there were. Only the orders having the status are written when it's set
+func ExportOrders(ctx context.Context, orders models.OrderRepository, w io.Writer, format, status string) (int, error) {
+	all, err := orders.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	list := []models.Order{}
+	for _, o := range all {
+		if status == "" || o.Status == status {
+			list = append(list, o)
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return len(list), encoder.Encode(list)
+	}
+	return len(list), models.WriteCSV(w, list)
+}
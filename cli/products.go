@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"GolangStore/config"
+	"GolangStore/models"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Create or update the products listed in a file or the standard input. The
+// products with an ID are updated, see ImportProducts
+func importProducts(cfg *config.Config, flags *flag.FlagSet, args []string) error {
+	format := flags.String("format", "", "csv or json, guessed from the file extension by default")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	} else if flags.NArg() > 1 {
+		return usageError(flags)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = "csv"
+		if strings.HasSuffix(path, ".json") {
+			*format = "json"
+		}
+	} else if *format != "csv" && *format != "json" {
+		return usageError(flags)
+	}
+
+	r := Stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// The products are all stored in one transaction, so that a failure
+	// halfway leaves the catalog as it was
+	return withStore(cfg, func(ctx context.Context, store *Store) error {
+		tx, err := store.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		created, updated, err := ImportProducts(ctx, models.NewPostgresProductRepository(tx), r, *format)
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "Created %d products, updated %d\n", created, updated)
+		return nil
+	})
+}
+
+// Read a list of products as CSV, with the columns of the CSV export, or as
+// JSON and store them. The products with an ID are updated and must exist,
+// the ones without one are created. Nothing is stored unless every product is valid. The
+// products are stored one after the other, so a failure of the repository
+// leaves the ones before it stored unless the repository works in a
+// transaction; the error tells which product failed
+func ImportProducts(ctx context.Context, products models.ProductRepository, r io.Reader, format string) (created, updated int, err error) {
+	list := []models.Product{}
+	if format == "json" {
+		err = json.NewDecoder(r).Decode(&list)
+	} else {
+		err = models.ReadCSV(r, &list)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range list {
+		if err := list[i].Validate(); err != nil {
+			return 0, 0, fmt.Errorf("product %d: %w", i+1, err)
+		}
+	}
+
+	for i := range list {
+		isNew, err := storeProduct(ctx, products, &list[i])
+		if err != nil {
+			return created, updated, fmt.Errorf("product %d: %w", i+1, err)
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+	return created, updated, nil
+}
+
+// Update the product when it has an ID or create it, and return whether it
+// was created. An ID that doesn't exist is rejected: creating the product
+// would give it another ID, so importing the same file again, like an export
+// of another database, would create it again
+func storeProduct(ctx context.Context, products models.ProductRepository, p *models.Product) (bool, error) {
+	if p.Id == 0 {
+		return true, products.Create(ctx, p)
+	}
+	if _, err := products.ByID(ctx, p.Id); err == models.ErrProductNotFound {
+		return false, fmt.Errorf("%w with the ID %d, leave the ID empty to create it", err, p.Id)
+	} else if err != nil {
+		return false, err
+	}
+	return false, products.Update(ctx, p)
+}
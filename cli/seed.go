@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"GolangStore/config"
+	"GolangStore/seed"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Fill an empty database with the demo data, the data of a fixture file or
+// random data. With -reset the database is emptied first, which is how a
+// seed that failed halfway is started over
+func seedCommand(cfg *config.Config, flags *flag.FlagSet, args []string) error {
+	fixture := flags.String("fixture", "", "the YAML or JSON file holding the data")
+	random := flags.Bool("random", false, "generate random data instead")
+	randomSeed := flags.Int64("seed", 1, "the seed of the random data, the same seed gives the same data")
+	size := seed.DefaultSize
+	flags.IntVar(&size.Users, "users", size.Users, "how many random users to generate")
+	flags.IntVar(&size.Articles, "articles", size.Articles, "how many random articles to generate")
+	flags.IntVar(&size.Products, "products", size.Products, "how many random products to generate")
+	flags.IntVar(&size.Categories, "categories", size.Categories, "how many random categories to generate")
+	flags.IntVar(&size.Orders, "orders", size.Orders, "how many random orders to generate")
+	printOnly := flags.Bool("print", false, "write the data as YAML instead of storing it")
+	reset := flags.Bool("reset", false, "delete the articles, products, categories and orders first, the users are kept")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	} else if flags.NArg() > 0 || (*fixture != "" && *random) {
+		return usageError(flags)
+	}
+
+	f, err := seedFixture(*fixture, *random, *randomSeed, size)
+	if err != nil {
+		return err
+	}
+	if *printOnly {
+		out, err := yaml.Marshal(f)
+		if err != nil {
+			return err
+		}
+		_, err = Stdout.Write(out)
+		return err
+	}
+
+	return withStore(cfg, func(ctx context.Context, store *Store) error {
+		if *reset {
+			if err := seed.Reset(ctx, store.DB); err != nil {
+				return err
+			}
+		}
+		summary, err := seed.Apply(ctx, f, seed.Target{
+			Users:      store.Users,
+			Articles:   store.Articles,
+			Products:   store.Products,
+			Categories: store.Categories,
+			Orders:     store.Orders,
+		})
+		if errors.Is(err, seed.ErrNotEmpty) {
+			return fmt.Errorf("%w, or pass -reset to delete its data first", err)
+		} else if err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "Created %d users, %d articles, %d products, %d categories and %d orders\n",
+			summary.Users, summary.Articles, summary.Products, summary.Categories, summary.Orders)
+		return nil
+	})
+}
+
+// Return the data the seed command stores
+func seedFixture(path string, random bool, randomSeed int64, size seed.Size) (*seed.Fixture, error) {
+	switch {
+	case path != "":
+		return seed.Load(path)
+	case random:
+		return seed.Generate(randomSeed, size), nil
+	}
+	return seed.Demo()
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"GolangStore/config"
+	"GolangStore/routes"
+	"flag"
+)
+
+// Serve the application until SIGINT or SIGTERM
+func serve(cfg *config.Config, flags *flag.FlagSet, args []string) error {
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	} else if flags.NArg() > 0 {
+		return usageError(flags)
+	}
+
+	// Setup Gin-Gonic
+	routes.GinSetup(cfg)
+	return nil
+}
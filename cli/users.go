@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"GolangStore/config"
+	"GolangStore/models"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Create a user with the given role. This is how the first administrator
+// of a production database is created
+func createUser(cfg *config.Config, flags *flag.FlagSet, args []string) error {
+	role := flags.String("role", models.RoleCustomer, "customer, editor or admin")
+	password := flags.String("password", "", "the password, read from the standard input when empty. "+
+		"It's visible to the other users of the machine when given here")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	} else if flags.NArg() != 1 || strings.TrimSpace(flags.Arg(0)) == "" {
+		return usageError(flags)
+	}
+	username := strings.TrimSpace(flags.Arg(0))
+	if !models.IsValidRole(*role) {
+		return models.ErrInvalidRole
+	}
+
+	if *password == "" {
+		var err error
+		if *password, err = readPassword(); err != nil {
+			return err
+		} else if *password == "" {
+			return errors.New("no password was given")
+		}
+	}
+
+	return withStore(cfg, func(ctx context.Context, store *Store) error {
+		u, err := models.CreateUser(ctx, store.Users, username, *password, *role)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "Created the %s %s\n", u.Role, u.Username)
+		return nil
+	})
+}
+
+// Read a password from the standard input. It isn't echoed when it's typed
+// in a terminal
+func readPassword() (string, error) {
+	fmt.Fprint(Stderr, "Password: ")
+	if f, ok := Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(Stderr)
+		return string(password), err
+	}
+
+	line, err := bufio.NewReader(Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The settings of the application. They are read from a YAML file, then
+// overridden by the environment variables listed in envOverrides
+type Config struct {
+	// The gin mode: debug, release or test
+	Mode     string         `yaml:"mode"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Cookies  CookieConfig   `yaml:"cookies"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Payments PaymentConfig  `yaml:"payments"`
+}
+
+type ServerConfig struct {
+	// The address to listen on, like :8080
+	Address string `yaml:"address"`
+
+	// The time allowed to read a whole request, or only its headers
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	// The time allowed to write the response
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// How long an idle keep-alive connection is kept open
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// How long the requests in progress are waited for when shutting down
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	// A complete connection string. When set, the other connection
+	// settings are ignored
+	DSN      string `yaml:"dsn"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+
+	// The limits of the connection pool. Zero open connections means no limit
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	// How many times the database is pinged at startup before giving up, and
+	// how long to wait after the first failure. The wait doubles every time
+	ConnectAttempts int           `yaml:"connect_attempts"`
+	ConnectBackoff  time.Duration `yaml:"connect_backoff"`
+
+	// How long a single query may take. Zero means no limit besides the one
+	// of the request
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+
+	// Whether the pending migrations are applied before serving
+	MigrateOnStart bool `yaml:"migrate_on_start"`
+}
+
+type CookieConfig struct {
+	// Only send the cookies over HTTPS
+	Secure bool `yaml:"secure"`
+	// One of lax, strict or none
+	SameSite string `yaml:"same_site"`
+}
+
+type AuthConfig struct {
+	// The secret signing the bearer tokens. A random one is used when it's
+	// empty, which logs out every API client on restart
+	TokenSecret string `yaml:"token_secret"`
+}
+
+// The modes of the fake payment provider
+const (
+	FakePaymentSucceed = "succeed"
+	FakePaymentDecline = "decline"
+	FakePaymentTimeout = "timeout"
+)
+
+type PaymentConfig struct {
+	// How the fake payment provider behaves: succeed, decline or timeout
+	FakeMode string `yaml:"fake_mode"`
+	// How long the fake provider takes to time out
+	FakeTimeout time.Duration `yaml:"fake_timeout"`
+	// The key the webhooks are signed with. They're refused while it's empty
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+// The file read when no other is given, if it exists
+const defaultFile = "config.yaml"
+
+// The environment variables overriding the settings, applied in this order
+var envOverrides = []struct {
+	name  string
+	field func(c *Config) interface{}
+}{
+	{"GIN_MODE", func(c *Config) interface{} { return &c.Mode }},
+	{"LISTEN_ADDR", func(c *Config) interface{} { return &c.Server.Address }},
+	{"SERVER_READ_TIMEOUT", func(c *Config) interface{} { return &c.Server.ReadTimeout }},
+	{"SERVER_READ_HEADER_TIMEOUT", func(c *Config) interface{} { return &c.Server.ReadHeaderTimeout }},
+	{"SERVER_WRITE_TIMEOUT", func(c *Config) interface{} { return &c.Server.WriteTimeout }},
+	{"SERVER_IDLE_TIMEOUT", func(c *Config) interface{} { return &c.Server.IdleTimeout }},
+	{"SERVER_SHUTDOWN_TIMEOUT", func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"DATABASE_URL", func(c *Config) interface{} { return &c.Database.DSN }},
+	{"PG_HOST", func(c *Config) interface{} { return &c.Database.Host }},
+	{"PG_PORT", func(c *Config) interface{} { return &c.Database.Port }},
+	{"PG_USER", func(c *Config) interface{} { return &c.Database.User }},
+	{"PG_PASS", func(c *Config) interface{} { return &c.Database.Password }},
+	{"PG_DB_STORE", func(c *Config) interface{} { return &c.Database.Name }},
+	{"PG_SSLMODE", func(c *Config) interface{} { return &c.Database.SSLMode }},
+	{"DB_MAX_OPEN_CONNS", func(c *Config) interface{} { return &c.Database.MaxOpenConns }},
+	{"DB_MAX_IDLE_CONNS", func(c *Config) interface{} { return &c.Database.MaxIdleConns }},
+	{"DB_CONN_MAX_LIFETIME", func(c *Config) interface{} { return &c.Database.ConnMaxLifetime }},
+	{"DB_CONN_MAX_IDLE_TIME", func(c *Config) interface{} { return &c.Database.ConnMaxIdleTime }},
+	{"DB_CONNECT_ATTEMPTS", func(c *Config) interface{} { return &c.Database.ConnectAttempts }},
+	{"DB_CONNECT_BACKOFF", func(c *Config) interface{} { return &c.Database.ConnectBackoff }},
+	{"DB_QUERY_TIMEOUT", func(c *Config) interface{} { return &c.Database.QueryTimeout }},
+	{"DB_MIGRATE_ON_START", func(c *Config) interface{} { return &c.Database.MigrateOnStart }},
+	{"COOKIE_SECURE", func(c *Config) interface{} { return &c.Cookies.Secure }},
+	{"COOKIE_SAMESITE", func(c *Config) interface{} { return &c.Cookies.SameSite }},
+	{"TOKEN_SECRET", func(c *Config) interface{} { return &c.Auth.TokenSecret }},
+	{"PAYMENT_FAKE_MODE", func(c *Config) interface{} { return &c.Payments.FakeMode }},
+	{"PAYMENT_FAKE_TIMEOUT", func(c *Config) interface{} { return &c.Payments.FakeTimeout }},
+	{"PAYMENT_WEBHOOK_SECRET", func(c *Config) interface{} { return &c.Payments.WebhookSecret }},
+}
+
+// Return the settings used when nothing else is specified
+func Default() *Config {
+	return &Config{
+		Mode: "debug",
+		Server: ServerConfig{
+			Address:           ":8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectAttempts: 5,
+			ConnectBackoff:  time.Second,
+			QueryTimeout:    5 * time.Second,
+		},
+		Cookies:  CookieConfig{SameSite: "lax"},
+		Payments: PaymentConfig{FakeMode: FakePaymentSucceed, FakeTimeout: 10 * time.Second},
+	}
+}
+
+// Read the settings from the file, or from CONFIG_FILE when path is empty,
+// or from config.yaml if it exists. The environment overrides them and the
+// result is validated
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		if _, err := os.Stat(defaultFile); err == nil {
+			path = defaultFile
+		}
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// Unknown keys are rejected so that typos don't go unnoticed
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Override the settings with the environment variables that are set
+func (c *Config) applyEnv() error {
+	// PORT is still honored, as gin used to listen on it
+	if port := os.Getenv("PORT"); port != "" {
+		c.Server.Address = ":" + port
+	}
+
+	for _, o := range envOverrides {
+		value, ok := os.LookupEnv(o.name)
+		if !ok {
+			continue
+		}
+		var err error
+		switch field := o.field(c).(type) {
+		case *string:
+			*field = value
+		case *int:
+			*field, err = strconv.Atoi(value)
+		case *bool:
+			*field, err = strconv.ParseBool(value)
+		case *time.Duration:
+			*field, err = time.ParseDuration(value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", o.name, err)
+		}
+	}
+	return nil
+}
+
+// Check that every setting holds an acceptable value. Every problem found
+// is reported in the error
+func (c *Config) Validate() error {
+	problems := []string{}
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(oneOf(c.Mode, "debug", "release", "test"), "mode must be debug, release or test")
+
+	_, _, err := net.SplitHostPort(c.Server.Address)
+	check(err == nil, "server.address must be a host:port address like :8080")
+	check(c.Server.ReadTimeout >= 0 && c.Server.ReadHeaderTimeout >= 0 && c.Server.WriteTimeout >= 0 &&
+		c.Server.IdleTimeout >= 0, "the server timeouts can't be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	d := c.Database
+	check(d.DSN != "" || oneOf(d.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		"database.sslmode must be disable, allow, prefer, require, verify-ca or verify-full")
+	check(d.MaxOpenConns >= 0, "database.max_open_conns can't be negative")
+	check(d.MaxIdleConns >= 0, "database.max_idle_conns can't be negative")
+	check(d.MaxOpenConns == 0 || d.MaxIdleConns <= d.MaxOpenConns,
+		"database.max_idle_conns can't be more than database.max_open_conns")
+	check(d.ConnMaxLifetime >= 0, "database.conn_max_lifetime can't be negative")
+	check(d.ConnMaxIdleTime >= 0, "database.conn_max_idle_time can't be negative")
+	check(d.ConnectAttempts > 0, "database.connect_attempts must be at least 1")
+	check(d.ConnectBackoff >= 0, "database.connect_backoff can't be negative")
+	check(d.QueryTimeout >= 0, "database.query_timeout can't be negative")
+
+	check(oneOf(c.Cookies.SameSite, "lax", "strict", "none"), "cookies.same_site must be lax, strict or none")
+	// Browsers drop the SameSite=None cookies that aren't secure
+	check(c.Cookies.SameSite != "none" || c.Cookies.Secure, "cookies.same_site none requires cookies.secure")
+
+	check(oneOf(c.Payments.FakeMode, FakePaymentSucceed, FakePaymentDecline, FakePaymentTimeout),
+		"payments.fake_mode must be %s, %s or %s", FakePaymentSucceed, FakePaymentDecline, FakePaymentTimeout)
+	check(c.Payments.FakeTimeout >= 0, "payments.fake_timeout can't be negative")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Return the connection string of the database
+func (d DatabaseConfig) ConnectionString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	settings := []string{}
+	for _, s := range []struct{ key, value string }{
+		{"host", d.Host},
+		{"port", d.Port},
+		{"user", d.User},
+		{"password", d.Password},
+		{"dbname", d.Name},
+		{"sslmode", d.SSLMode},
+	} {
+		if s.value != "" {
+			settings = append(settings, s.key+"="+quoteSetting(s.value))
+		}
+	}
+	return strings.Join(settings, " ")
+}
+
+// Return the SameSite attribute of the cookies
+func (c CookieConfig) SameSiteMode() http.SameSite {
+	switch c.SameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+// Quote a value of a connection string, as needed when it's empty or holds
+// spaces or quotes
+func quoteSetting(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -1,24 +1,80 @@
 package database
 
 import (
+	"GolangStore/config"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
-// Get the environment credentials and opens a connection with the database
-func Connect() *sql.DB {
-	password := os.Getenv("PG_PASS")
-	user := os.Getenv("PG_USER")
-	dbName := os.Getenv("PG_DB_STORE")
-	host := os.Getenv("PG_HOST")
-	connection := fmt.Sprintf("user=%s dbname=%s password=%s host=%s sslmode=disable", user, dbName, password, host)
-	db, err := sql.Open("postgres", connection)
+// How long a single ping may take before being considered failed
+const pingTimeout = 5 * time.Second
+
+// The longest wait between two pings at startup
+const maxBackoff = 30 * time.Second
+
+// Pinger is implemented by *sql.DB. It's what the health checks need from
+// the database
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Open a connection pool with the database, sized by the configuration.
+// The pool is safe for concurrent use, so it's meant to be opened once at
+// startup and shared by every repository. The database is pinged before
+// returning so that a wrong configuration or an unreachable server is
+// reported right away
+func Connect(ctx context.Context, cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.ConnectionString())
 	if err != nil {
-		log.Println("Unable to connect:" + err.Error())
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := WaitForDatabase(ctx, db, cfg.ConnectAttempts, cfg.ConnectBackoff); err != nil {
+		db.Close()
+		return nil, err
 	}
-	return db
+	return db, nil
+}
+
+// Ping the database until it answers, at most the given number of times.
+// The wait between two attempts starts at backoff and doubles after every
+// failure. The last error is returned when the database never answers
+func WaitForDatabase(ctx context.Context, db Pinger, attempts int, backoff time.Duration) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = Ping(ctx, db); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		log.Printf("The database is unreachable (attempt %d of %d), retrying in %s: %v",
+			attempt, attempts, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return fmt.Errorf("the database is unreachable after %d attempts: %w", attempts, err)
+}
+
+// Ping the database once, giving up after pingTimeout
+func Ping(ctx context.Context, db Pinger) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
 }
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The migrations are built into the binary so that it can set up the schema
+// of any environment on its own
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// A versioned change of the schema along with the way to undo it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// A migration and when it was applied, nil when it's pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// The files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// The applied migrations are recorded in this table
+const createMigrationsTable = `create table if not exists schema_migrations (
+	version    integer primary key,
+	name       text not null,
+	applied_at timestamptz not null default now()
+)`
+
+// The key of the advisory lock keeping two processes from migrating the
+// same database at once
+const migrationLock = 4242
+
+// Return the migrations built into the binary, by increasing version
+func Migrations() ([]Migration, error) {
+	dir, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return ReadMigrations(dir)
+}
+
+// Read the migrations found at the root of the file system, by increasing
+// version. Every version needs both an up and a down file
+func ReadMigrations(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range names {
+		match := migrationFileName.FindStringSubmatch(file)
+		if match == nil {
+			return nil, fmt.Errorf("the migration %s isn't named <version>_<name>.up|down.sql", file)
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("the migrations %s and %d_%s share the same version", file, version, m.Name)
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("the migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Apply the pending migrations and return them
+func MigrateUp(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			continue
+		}
+		done, err := runMigration(ctx, db, s.Migration, true)
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			applied = append(applied, s.Migration)
+		}
+	}
+	return applied, nil
+}
+
+// Undo the given number of migrations, starting from the latest applied
+// one, and return them
+func MigrateDown(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []Migration{}
+	for i := len(statuses) - 1; i >= 0 && len(reverted) < steps; i-- {
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+		done, err := runMigration(ctx, db, statuses[i].Migration, false)
+		if err != nil {
+			return reverted, err
+		}
+		if done {
+			reverted = append(reverted, statuses[i].Migration)
+		}
+	}
+	return reverted, nil
+}
+
+// Return every migration built into the binary along with when it was
+// applied. The migrations table is created when missing
+func Status(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if at, ok := appliedAt[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Apply or undo a migration in a transaction, along with its record in the
+// migrations table. Nothing is done when another process got there first,
+// which is reported by returning false
+func runMigration(ctx context.Context, db *sql.DB, m Migration, up bool) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// The lock is released at the end of the transaction
+	if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		return false, err
+	}
+	var applied bool
+	err = tx.QueryRowContext(ctx, "select exists (select 1 from schema_migrations where version = $1)",
+		m.Version).Scan(&applied)
+	if err != nil || applied == up {
+		return false, err
+	}
+
+	script, record := m.Down, "delete from schema_migrations where version = $1"
+	if up {
+		script, record = m.Up, "insert into schema_migrations (version, name) values ($1, $2)"
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return false, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+	}
+	args := []interface{}{m.Version}
+	if up {
+		args = append(args, m.Name)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
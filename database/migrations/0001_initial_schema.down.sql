@@ -0,0 +1,8 @@
+-- The first migration adopts the tables of the databases set up before the
+-- migrations, so dropping its tables could delete data it never created. It
+-- can't be undone, drop the tables by hand to start over
+do $$
+begin
+    raise exception 'the migration 0001_initial_schema can''t be undone, it may have adopted tables holding data';
+end
+$$;
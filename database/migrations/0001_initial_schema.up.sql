@@ -0,0 +1,108 @@
+-- The tables used by the Postgres repositories. They are only created when
+-- missing so that the databases set up by hand before the migrations can
+-- adopt them. The columns added to the existing tables since then are added
+-- to those databases at the end
+
+create table if not exists products (
+    id          serial primary key,
+    name        text not null,
+    description text not null default '',
+    price       numeric(12, 2) not null check (price >= 0),
+    quantity    integer not null check (quantity >= 0)
+);
+
+create table if not exists articles (
+    id      serial primary key,
+    title   text not null,
+    content text not null
+);
+
+-- password_hash holds a bcrypt hash. Rows copied from an older setup may
+-- still hold the plain password, which the migration 0003 hashes
+create table if not exists users (
+    username      text primary key,
+    password_hash text not null,
+    role          text not null default 'customer' check (role in ('customer', 'editor', 'admin')),
+    created_at    timestamptz not null default now()
+);
+
+-- Only the SHA-256 of the session tokens is stored
+create table if not exists sessions (
+    token_hash text primary key,
+    username   text not null references users (username) on delete cascade,
+    expires_at timestamptz not null
+);
+
+create index if not exists sessions_expires_at_idx on sessions (expires_at);
+
+-- id stays the same when the refresh token is rotated and is carried by the
+-- access tokens issued for it, so deleting the row revokes them all
+create table if not exists refresh_tokens (
+    id         text primary key,
+    token_hash text not null unique,
+    username   text not null references users (username) on delete cascade,
+    expires_at timestamptz not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists refresh_tokens_expires_at_idx on refresh_tokens (expires_at);
+
+-- Only the hash of the key is kept, prefix is shown to tell the keys apart
+create table if not exists api_keys (
+    id           serial primary key,
+    username     text not null references users (username) on delete cascade,
+    name         text not null,
+    key_hash     text not null unique,
+    prefix       text not null,
+    scopes       text[] not null,
+    created_at   timestamptz not null default now(),
+    last_used_at timestamptz
+);
+
+create index if not exists api_keys_username_idx on api_keys (username);
+
+-- owner is either "anonymous:<cart cookie>" or "user:<username>"
+create table if not exists cart_items (
+    owner      text not null,
+    product_id integer not null references products (id) on delete cascade,
+    quantity   integer not null check (quantity > 0),
+    added_at   timestamptz not null default now(),
+    primary key (owner, product_id)
+);
+
+create table if not exists orders (
+    id         serial primary key,
+    username   text not null references users (username),
+    status     text not null,
+    total      numeric(12, 2) not null,
+    payment_id text,
+    created_at timestamptz not null default now()
+);
+
+-- name and price are copied from the product when the order is placed
+create table if not exists order_items (
+    id         serial primary key,
+    order_id   integer not null references orders (id) on delete cascade,
+    product_id integer references products (id) on delete set null,
+    name       text not null,
+    price      numeric(12, 2) not null,
+    quantity   integer not null check (quantity > 0)
+);
+
+-- from_status is null for the entry written when the order is placed
+create table if not exists order_status_history (
+    id          serial primary key,
+    order_id    integer not null references orders (id) on delete cascade,
+    from_status text,
+    to_status   text not null,
+    changed_by  text not null,
+    changed_at  timestamptz not null default now()
+);
+
+create index if not exists order_status_history_order_id_idx on order_status_history (order_id);
+
+-- The columns that the tables created from an older schema.sql don't have
+alter table users
+    add column if not exists role text not null default 'customer' check (role in ('customer', 'editor', 'admin'));
+
+alter table orders add column if not exists payment_id text;
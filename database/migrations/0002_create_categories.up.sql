@@ -0,0 +1,11 @@
+create table categories (
+    id   serial primary key,
+    name text not null unique
+);
+
+-- A product can belong to several categories
+create table product_categories (
+    product_id  integer not null references products (id) on delete cascade,
+    category_id integer not null references categories (id) on delete cascade,
+    primary key (product_id, category_id)
+);
@@ -0,0 +1,2 @@
+-- The plain passwords can't be recovered from their hashes, and the hashes
+-- keep working, so there's nothing to undo
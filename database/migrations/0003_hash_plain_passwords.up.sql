@@ -0,0 +1,8 @@
+-- The users adopted from the setups older than the password hashing may
+-- still hold their plain password. Hash it the way the store does, so that
+-- the logins only ever compare bcrypt hashes. pgcrypto is a trusted
+-- extension since Postgres 13, the owner of the database can create it
+create extension if not exists pgcrypto;
+
+update users set password_hash = crypt(password_hash, gen_salt('bf', 10))
+    where password_hash <> '' and password_hash !~ '^\$2[aby]\$';
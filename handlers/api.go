@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Body of the API responses carrying data. The errors are described by
+// middleware.APIError
+type APIResponse struct {
+	Data interface{} `json:"data"`
+}
+
+// Respond to an API request with the data wrapped in the envelope
+func respondAPI(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, APIResponse{Data: data})
+}
+
+// Abort an API request because the resource doesn't exist
+func apiNotFound(c *gin.Context, err error) {
+	middleware.AbortWithAPIError(c, http.StatusNotFound, middleware.ErrCodeNotFound, err.Error())
+}
+
+// Abort an API request because of a failure of the server. The details are
+// logged but not shown to the client. An unavailable database or a query
+// taking too long is reported with a 503 so that the client knows it can
+// retry
+func apiInternalError(c *gin.Context, err error) {
+	c.Error(err)
+	if clientCanceled(err) {
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+	if queryTimedOut(err) {
+		c.Header("Retry-After", retryAfter)
+		middleware.AbortWithAPIError(c, http.StatusServiceUnavailable, middleware.ErrCodeTimeout,
+			http.StatusText(http.StatusServiceUnavailable))
+		return
+	}
+	if errors.Is(err, models.ErrUnavailable) {
+		c.Header("Retry-After", retryAfter)
+		middleware.AbortWithAPIError(c, http.StatusServiceUnavailable, middleware.ErrCodeUnavailable,
+			http.StatusText(http.StatusServiceUnavailable))
+		return
+	}
+	middleware.AbortWithAPIError(c, http.StatusInternalServerError, middleware.ErrCodeInternal,
+		http.StatusText(http.StatusInternalServerError))
+}
+
+// Abort an API request because the submitted values were rejected
+func apiValidationError(c *gin.Context, err error) {
+	middleware.AbortWithAPIError(c, http.StatusUnprocessableEntity, middleware.ErrCodeValidation, err.Error())
+}
+
+// Decode the JSON body of an API request, aborting it when it's malformed
+func bindAPIBody(c *gin.Context, v interface{}) bool {
+	if err := c.ShouldBindJSON(v); err != nil {
+		middleware.AbortWithAPIError(c, http.StatusBadRequest, middleware.ErrCodeBadRequest,
+			"the request body isn't valid JSON")
+		return false
+	}
+	return true
+}
+
+// Read a numeric ID from the URL, aborting with a not found error when it
+// isn't one
+func apiID(c *gin.Context, param string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil {
+		middleware.AbortWithAPIError(c, http.StatusNotFound, middleware.ErrCodeNotFound,
+			"the "+param+" isn't valid")
+		return 0, false
+	}
+	return id, true
+}
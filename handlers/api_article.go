@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"GolangStore/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handler listing the articles
+func APIGetArticles(c *gin.Context) {
+	articles, err := Articles.All(c.Request.Context())
+	if err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	respondAPI(c, http.StatusOK, articles)
+}
+
+// handler returning a single article
+func APIGetArticle(c *gin.Context) {
+	articleID, ok := apiID(c, "article_id")
+	if !ok {
+		return
+	}
+
+	if article, err := Articles.ByID(c.Request.Context(), articleID); err == nil {
+		respondAPI(c, http.StatusOK, article)
+	} else if err == models.ErrArticleNotFound {
+		apiNotFound(c, err)
+	} else {
+		apiInternalError(c, err)
+	}
+}
+
+// handler creating an article out of the JSON body
+func APICreateArticle(c *gin.Context) {
+	var a models.Article
+	if !bindAPIBody(c, &a) {
+		return
+	}
+	if err := a.Validate(); err != nil {
+		apiValidationError(c, err)
+		return
+	}
+
+	if err := Articles.Create(c.Request.Context(), &a); err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	c.Header("Location", "/api/v1/articles/"+strconv.Itoa(a.ID))
+	respondAPI(c, http.StatusCreated, a)
+}
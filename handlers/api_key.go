@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"GolangStore/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The body POSTed to create an API key
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// handler listing the API keys of the logged in user
+func APIGetKeys(c *gin.Context) {
+	keys, err := APIKeys.ByUser(c.Request.Context(), c.GetString("username"))
+	if err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	respondAPI(c, http.StatusOK, keys)
+}
+
+// handler creating an API key for the logged in user. The key is only
+// returned this once
+func APICreateKey(c *gin.Context) {
+	var request APIKeyRequest
+	if !bindAPIBody(c, &request) {
+		return
+	}
+
+	key, err := models.CreateAPIKey(c.Request.Context(), APIKeys, c.GetString("username"), request.Name, request.Scopes)
+	if err == models.ErrInvalidAPIKey {
+		apiValidationError(c, err)
+		return
+	} else if err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	respondAPI(c, http.StatusCreated, key)
+}
+
+// handler revoking an API key of the logged in user
+func APIDeleteKey(c *gin.Context) {
+	keyID, ok := apiID(c, "key_id")
+	if !ok {
+		return
+	}
+
+	if err := APIKeys.Delete(c.Request.Context(), c.GetString("username"), keyID); err == nil {
+		c.Status(http.StatusNoContent)
+	} else if err == models.ErrAPIKeyNotFound {
+		apiNotFound(c, err)
+	} else {
+		apiInternalError(c, err)
+	}
+}
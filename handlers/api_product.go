@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"GolangStore/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handler listing the products
+func APIGetProducts(c *gin.Context) {
+	products, err := Products.All(c.Request.Context())
+	if err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	respondAPI(c, http.StatusOK, products)
+}
+
+// handler returning a single product
+func APIGetProduct(c *gin.Context) {
+	productID, ok := apiID(c, "product_id")
+	if !ok {
+		return
+	}
+
+	if product, err := Products.ByID(c.Request.Context(), productID); err == nil {
+		respondAPI(c, http.StatusOK, product)
+	} else if err == models.ErrProductNotFound {
+		apiNotFound(c, err)
+	} else {
+		apiInternalError(c, err)
+	}
+}
+
+// handler creating a product out of the JSON body
+func APICreateProduct(c *gin.Context) {
+	var p models.Product
+	if !bindAPIBody(c, &p) {
+		return
+	}
+	if err := p.Validate(); err != nil {
+		apiValidationError(c, err)
+		return
+	}
+
+	if err := Products.Create(c.Request.Context(), &p); err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	c.Header("Location", "/api/v1/products/"+strconv.Itoa(p.Id))
+	respondAPI(c, http.StatusCreated, p)
+}
+
+// handler replacing a product with the JSON body
+func APIUpdateProduct(c *gin.Context) {
+	productID, ok := apiID(c, "product_id")
+	if !ok {
+		return
+	}
+	var p models.Product
+	if !bindAPIBody(c, &p) {
+		return
+	}
+	p.Id = productID
+	if err := p.Validate(); err != nil {
+		apiValidationError(c, err)
+		return
+	}
+
+	if err := Products.Update(c.Request.Context(), &p); err == nil {
+		respondAPI(c, http.StatusOK, p)
+	} else if err == models.ErrProductNotFound {
+		apiNotFound(c, err)
+	} else {
+		apiInternalError(c, err)
+	}
+}
+
+// handler deleting a product
+func APIDeleteProduct(c *gin.Context) {
+	productID, ok := apiID(c, "product_id")
+	if !ok {
+		return
+	}
+
+	if err := Products.Delete(c.Request.Context(), productID); err == nil {
+		c.Status(http.StatusNoContent)
+	} else if err == models.ErrProductNotFound {
+		apiNotFound(c, err)
+	} else {
+		apiInternalError(c, err)
+	}
+}
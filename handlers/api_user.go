@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The credentials POSTed to register or log in through the API
+type APICredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// handler registering a new customer. No session is started, the client
+// has to log in afterwards
+func APIRegister(c *gin.Context) {
+	var credentials APICredentials
+	if !bindAPIBody(c, &credentials) {
+		return
+	}
+
+	user, err := models.RegisterNewUser(c.Request.Context(), Users, credentials.Username, credentials.Password)
+	switch err {
+	case nil:
+		respondAPI(c, http.StatusCreated, user)
+	case models.ErrUsernameTaken:
+		middleware.AbortWithAPIError(c, http.StatusConflict, middleware.ErrCodeConflict, err.Error())
+	case models.ErrEmptyPassword:
+		apiValidationError(c, err)
+	default:
+		apiInternalError(c, err)
+	}
+}
+
+// handler returning the logged in user
+func APIGetCurrentUser(c *gin.Context) {
+	if user, err := Users.ByUsername(c.Request.Context(), c.GetString("username")); err == nil {
+		respondAPI(c, http.StatusOK, user)
+	} else if err == models.ErrUserNotFound {
+		apiNotFound(c, err)
+	} else {
+		apiInternalError(c, err)
+	}
+}
+
+// handler starting a session, whose token is set in a cookie like for the
+// login page
+func APILogin(c *gin.Context) {
+	var credentials APICredentials
+	if !bindAPIBody(c, &credentials) {
+		return
+	}
+
+	valid, err := models.IsUserValid(c.Request.Context(), Users, credentials.Username, credentials.Password)
+	if err != nil {
+		apiInternalError(c, err)
+		return
+	} else if !valid {
+		middleware.AbortWithAPIError(c, http.StatusUnauthorized, middleware.ErrCodeUnauthorized,
+			"invalid credentials provided")
+		return
+	}
+	user, err := Users.ByUsername(c.Request.Context(), credentials.Username)
+	if err == nil {
+		err = startSession(c, user)
+	}
+	if err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	respondAPI(c, http.StatusOK, user)
+}
+
+// handler ending the session of the logged in user. The refresh token the
+// request was authenticated with, or the one optionally POSTed as
+// refresh_token, is revoked so that it can't issue access tokens anymore
+func APILogout(c *gin.Context) {
+	var request APITokenRequest
+	if c.Request.ContentLength != 0 && !bindAPIBody(c, &request) {
+		return
+	}
+
+	err := endSession(c)
+	if accessToken := c.GetString("access_token"); err == nil && accessToken != "" {
+		err = Tokens.RevokeAccess(c.Request.Context(), accessToken)
+	}
+	if err == nil && request.RefreshToken != "" {
+		err = Tokens.Revoke(c.Request.Context(), request.RefreshToken)
+	}
+	if err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// The body POSTed to /api/v1/auth/token. The password grant needs the
+// credentials of the user, the refresh_token grant a refresh token
+type APITokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// handler issuing bearer tokens, either for the credentials of a user or in
+// exchange for a refresh token
+func APIToken(c *gin.Context) {
+	var request APITokenRequest
+	if !bindAPIBody(c, &request) {
+		return
+	}
+
+	var tokens *models.TokenPair
+	var err error
+	switch request.GrantType {
+	case "password":
+		var valid bool
+		if valid, err = models.IsUserValid(c.Request.Context(), Users, request.Username, request.Password); err != nil {
+			break
+		} else if !valid {
+			middleware.AbortWithAPIError(c, http.StatusUnauthorized, middleware.ErrCodeUnauthorized,
+				"invalid credentials provided")
+			return
+		}
+		tokens, err = Tokens.Issue(c.Request.Context(), request.Username)
+	case "refresh_token":
+		tokens, err = Tokens.Refresh(c.Request.Context(), request.RefreshToken)
+	default:
+		middleware.AbortWithAPIError(c, http.StatusBadRequest, middleware.ErrCodeBadRequest,
+			"the grant_type must be password or refresh_token")
+		return
+	}
+
+	if err == models.ErrRefreshTokenNotFound {
+		middleware.AbortWithAPIError(c, http.StatusUnauthorized, middleware.ErrCodeUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	// The tokens must not be kept by any cache on the way
+	c.Header("Cache-Control", "no-store")
+	respondAPI(c, http.StatusOK, tokens)
+}
+
+// handler revoking a refresh token along with the access tokens issued for it.
+// Unknown tokens are ignored so that clients can't probe for valid ones
+func APIRevokeToken(c *gin.Context) {
+	var request APITokenRequest
+	if !bindAPIBody(c, &request) {
+		return
+	}
+
+	if err := Tokens.Revoke(c.Request.Context(), request.RefreshToken); err != nil {
+		apiInternalError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
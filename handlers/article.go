@@ -9,7 +9,11 @@ import (
 )
 
 func ShowIndexPage(c *gin.Context) {
-	articles := models.GetAllArticles()
+	articles, err := Articles.All(c.Request.Context())
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
 
 	// Call the render function with the name of the template to render
 	render(
@@ -33,16 +37,18 @@ func GetArticle(c *gin.Context) {
 	// Check if the article ID is valid
 	if articleID, err := strconv.Atoi(c.Param("article_id")); err == nil {
 		// Check if the article exists
-		if article, err := models.GetArticleByID(articleID); err == nil {
+		if article, err := Articles.ByID(c.Request.Context(), articleID); err == nil {
 			// Call the render function with the title, article and the name of the
 			// template
 			render(c, gin.H{
 				"title":   article.Title,
 				"payload": article}, "article.html")
 
-		} else {
+		} else if err == models.ErrArticleNotFound {
 			// If the article is not found, abort with an error
 			c.AbortWithError(http.StatusNotFound, err)
+		} else {
+			showServerError(c, err)
 		}
 
 	} else {
@@ -53,16 +59,25 @@ func GetArticle(c *gin.Context) {
 
 func CreateArticle(c *gin.Context) {
 	// Obtain the POSTed title and content values
-	title := c.PostForm("title")
-	content := c.PostForm("content")
+	a := &models.Article{Title: c.PostForm("title"), Content: c.PostForm("content")}
+	if err := a.Validate(); err != nil {
+		// Show the form again along with the reason it was rejected
+		data := gin.H{
+			"title":        "Create New Article",
+			"ErrorTitle":   "Invalid Article",
+			"ErrorMessage": err.Error()}
+		setPageData(c, data)
+		c.HTML(http.StatusBadRequest, "create-article.html", data)
+		return
+	}
 
-	if a, err := models.CreateNewArticle(title, content); err == nil {
+	if err := Articles.Create(c.Request.Context(), a); err == nil {
 		// If the article is created successfully, show success message
 		render(c, gin.H{
 			"title":   "Submission Successful",
 			"payload": a}, "submission-successful.html")
 	} else {
-		// if there was an error while creating the article, abort with an error
-		c.AbortWithStatus(http.StatusBadRequest)
+		// if there was an error while storing the article, abort with an error
+		showServerError(c, err)
 	}
 }
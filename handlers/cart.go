@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"GolangStore/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Name of the cookie identifying the cart of a visitor that isn't logged in
+const cartCookie = "cart"
+
+// How long the browser keeps the cart of a visitor that isn't logged in
+const cartCookieMaxAge = 30 * 24 * 3600
+
+func ShowCart(c *gin.Context) {
+	owner, err := cartOwner(c, false)
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
+	showCart(c, owner)
+}
+
+func AddToCart(c *gin.Context) {
+	productID, quantity, err := cartItemFromForm(c, 1)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	owner, err := cartOwner(c, true)
+	if err == nil {
+		err = models.AddToCart(c.Request.Context(), Carts, Products, owner, productID, quantity)
+	}
+	if err != nil {
+		cartError(c, owner, err)
+		return
+	}
+	showCart(c, owner)
+}
+
+func UpdateCart(c *gin.Context) {
+	productID, quantity, err := cartItemFromForm(c, 0)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	owner, err := cartOwner(c, true)
+	if err == nil {
+		err = models.UpdateCartQuantity(c.Request.Context(), Carts, Products, owner, productID, quantity)
+	}
+	if err != nil {
+		cartError(c, owner, err)
+		return
+	}
+	showCart(c, owner)
+}
+
+func RemoveFromCart(c *gin.Context) {
+	productID, _, err := cartItemFromForm(c, 0)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	owner, err := cartOwner(c, true)
+	if err == nil {
+		err = Carts.Remove(c.Request.Context(), owner, productID)
+	}
+	if err != nil {
+		cartError(c, owner, err)
+		return
+	}
+	showCart(c, owner)
+}
+
+// Render the owner's cart
+func showCart(c *gin.Context, owner string) {
+	cart, err := models.LoadCart(c.Request.Context(), Carts, Products, owner)
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
+	render(c, gin.H{
+		"title":   "Shopping Cart",
+		"payload": cart}, "cart.html")
+}
+
+// Show the cart again along with the reason the change or the checkout was rejected
+func cartError(c *gin.Context, owner string, err error) {
+	switch err {
+	case models.ErrProductNotFound:
+		c.AbortWithError(http.StatusNotFound, err)
+	case models.ErrInsufficientStock, models.ErrInvalidQuantity, models.ErrEmptyCart,
+		models.ErrPaymentDeclined, models.ErrPaymentTimeout:
+		cart, loadErr := models.LoadCart(c.Request.Context(), Carts, Products, owner)
+		if loadErr != nil {
+			showServerError(c, loadErr)
+			return
+		}
+		code := http.StatusBadRequest
+		if err == models.ErrPaymentDeclined || err == models.ErrPaymentTimeout {
+			code = http.StatusPaymentRequired
+		}
+		data := gin.H{
+			"title":        "Shopping Cart",
+			"payload":      cart,
+			"ErrorTitle":   "Unable to update the cart",
+			"ErrorMessage": err.Error()}
+		setPageData(c, data)
+		c.HTML(code, "cart.html", data)
+	default:
+		showServerError(c, err)
+	}
+}
+
+// Return the owner of the current visitor's cart. Logged in users own their
+// cart, anonymous visitors are identified by a cookie which is handed out
+// when create is set and they don't have one yet
+func cartOwner(c *gin.Context, create bool) (string, error) {
+	if c.GetBool("is_logged_in") {
+		return models.UserCartOwner(c.GetString("username")), nil
+	}
+
+	id, err := c.Cookie(cartCookie)
+	if err != nil || id == "" {
+		if !create {
+			// Without a cookie the cart is empty
+			return models.AnonymousCartOwner(""), nil
+		}
+		if id, err = models.NewSessionToken(); err != nil {
+			return "", err
+		}
+		setCookie(c, cartCookie, id, cartCookieMaxAge)
+	}
+	return models.AnonymousCartOwner(id), nil
+}
+
+// Move the cart filled before logging in into the cart of the user
+func mergeAnonymousCart(c *gin.Context, username string) error {
+	id, err := c.Cookie(cartCookie)
+	if err != nil || id == "" {
+		return nil
+	}
+	if err := Carts.Merge(c.Request.Context(), models.AnonymousCartOwner(id), models.UserCartOwner(username)); err != nil {
+		return err
+	}
+	setCookie(c, cartCookie, "", -1)
+	return nil
+}
+
+// Read the POSTed product ID and quantity. The quantity is optional and
+// defaults to the given value
+func cartItemFromForm(c *gin.Context, defaultQuantity int) (int, int, error) {
+	productID, err := strconv.Atoi(c.PostForm("product_id"))
+	if err != nil {
+		return 0, 0, errInvalidField("product_id")
+	}
+
+	quantity := defaultQuantity
+	if q, ok := c.GetPostForm("quantity"); ok {
+		if quantity, err = strconv.Atoi(q); err != nil {
+			return 0, 0, errInvalidField("quantity")
+		}
+	}
+	return productID, quantity, nil
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The attributes of the cookies set by the handlers. GinSetup sets them from
+// the configuration; Secure must be set when serving over HTTPS
+var (
+	SecureCookies  = false
+	CookieSameSite = http.SameSiteLaxMode
+)
+
+// Set an HTTP only cookie valid for the whole site. A negative maxAge
+// deletes it
+func setCookie(c *gin.Context, name, value string, maxAge int) {
+	c.SetSameSite(CookieSameSite)
+	c.SetCookie(name, value, maxAge, "", "", SecureCookies, true)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"GolangStore/models"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How many seconds the clients are asked to wait before retrying when the
+// database is unavailable
+const retryAfter = "30"
+
+// The status, borrowed from nginx, of the requests the client gave up on.
+// Nobody reads the response, it only shows in the logs
+const statusClientClosedRequest = 499
+
+// Whether the queries were stopped because the client went away
+func clientCanceled(err error) bool {
+	var canceled *models.CanceledError
+	return errors.As(err, &canceled) && !canceled.Timeout()
+}
+
+// Whether the queries were stopped because they took longer than
+// models.QueryTimeout
+func queryTimedOut(err error) bool {
+	var canceled *models.CanceledError
+	return errors.As(err, &canceled) && canceled.Timeout()
+}
+
+// Abort the request because of a failure of the server and show an error
+// page. The details are logged but not shown to the client. An unavailable
+// database or a query taking too long is reported with a 503 so that the
+// client knows it can retry
+func showServerError(c *gin.Context, err error) {
+	c.Error(err)
+	if clientCanceled(err) {
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+	status := http.StatusInternalServerError
+	message := "Something went wrong on our side, please try again."
+	if errors.Is(err, models.ErrUnavailable) || queryTimedOut(err) {
+		status = http.StatusServiceUnavailable
+		message = "The store is temporarily unavailable, please try again in a moment."
+		c.Header("Retry-After", retryAfter)
+	}
+
+	// Only the browsers get a page, the other formats get the bare status
+	if format, ok := negotiateFormat(c); !ok || format != formatHTML {
+		c.AbortWithStatus(status)
+		return
+	}
+	data := gin.H{
+		"title":        http.StatusText(status),
+		"ErrorTitle":   http.StatusText(status),
+		"ErrorMessage": message}
+	setPageData(c, data)
+	c.HTML(status, "error.html", data)
+	c.Abort()
+}
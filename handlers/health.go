@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"GolangStore/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Body of the health checks
+type HealthStatus struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+}
+
+// The database the readiness check pings. It's nil while the handlers work
+// with the in-memory backends, which are always available
+var Database database.Pinger
+
+// Report that the process is alive along with the state of the database.
+// It succeeds during a database outage so that the process doesn't get
+// restarted for nothing
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthStatus{Status: "ok", Database: databaseStatus(c)})
+}
+
+// Report whether the requests can be served, which needs the database to
+// be reachable
+func Readyz(c *gin.Context) {
+	status := databaseStatus(c)
+	if status == "unreachable" {
+		c.JSON(http.StatusServiceUnavailable, HealthStatus{Status: "unavailable", Database: status})
+		return
+	}
+	c.JSON(http.StatusOK, HealthStatus{Status: "ready", Database: status})
+}
+
+// Ping the database and describe its state. The reason of a failure is
+// logged but not shown to the client
+func databaseStatus(c *gin.Context) string {
+	if Database == nil {
+		return "memory"
+	}
+	if err := database.Ping(c.Request.Context(), Database); err != nil {
+		c.Error(err)
+		return "unreachable"
+	}
+	return "ok"
+}
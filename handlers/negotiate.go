@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The formats render can produce
+const (
+	formatHTML = "html"
+	formatJSON = "json"
+	formatXML  = "xml"
+	formatCSV  = "csv"
+)
+
+// The media types of each format, in the order the formats are preferred
+// when the client accepts several of them equally
+var formatMediaTypes = []struct {
+	format     string
+	mediaTypes []string
+}{
+	{formatHTML, []string{"text/html"}},
+	{formatJSON, []string{"application/json"}},
+	{formatXML, []string{"application/xml", "text/xml"}},
+	{formatCSV, []string{"text/csv"}},
+}
+
+// A media range of the Accept header along with its quality
+type acceptedRange struct {
+	mainType, subType string
+	q                 float64
+}
+
+// Pick the format of the response. A format given in the URL, either as the
+// format query parameter or as a suffix like .json, wins over the Accept
+// header. It returns false when the client accepts none of the formats
+func negotiateFormat(c *gin.Context) (string, bool) {
+	if format := c.Query("format"); format != "" {
+		for _, f := range formatMediaTypes {
+			if f.format == format {
+				return format, true
+			}
+		}
+		return "", false
+	}
+
+	accept := c.GetHeader("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formatHTML, true
+	}
+	ranges := parseAccept(accept)
+
+	best, bestQ := "", 0.0
+	for _, f := range formatMediaTypes {
+		for _, mediaType := range f.mediaTypes {
+			if q := acceptQuality(ranges, mediaType); q > bestQ {
+				best, bestQ = f.format, q
+			}
+		}
+	}
+	return best, bestQ > 0
+}
+
+// Parse the media ranges of an Accept header. Ranges with an invalid
+// quality are ignored
+func parseAccept(header string) []acceptedRange {
+	ranges := []acceptedRange{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+		slash := strings.Index(mediaRange, "/")
+		if slash < 0 {
+			continue
+		}
+
+		r := acceptedRange{mainType: mediaRange[:slash], subType: mediaRange[slash+1:], q: 1}
+		valid := true
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || strings.ToLower(kv[0]) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			r.q = q
+		}
+		if valid {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// Return the quality the client gives to a media type, taken from the most
+// specific range matching it
+func acceptQuality(ranges []acceptedRange, mediaType string) float64 {
+	slash := strings.Index(mediaType, "/")
+	mainType, subType := mediaType[:slash], mediaType[slash+1:]
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mainType == mainType && r.subType == subType:
+			s = 2
+		case r.mainType == mainType && r.subType == "*":
+			s = 1
+		case r.mainType == "*" && r.subType == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
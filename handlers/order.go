@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"GolangStore/models"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Checkout(c *gin.Context) {
+	owner, err := cartOwner(c, false)
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
+
+	order, err := models.Checkout(c.Request.Context(), Carts, Orders, Payments, owner, c.GetString("username"))
+	if err != nil {
+		// If the order couldn't be placed, show the cart again with the reason
+		cartError(c, owner, err)
+		return
+	}
+
+	render(c, gin.H{
+		"title":   "Order Confirmation",
+		"payload": order}, "order-confirmation.html")
+}
+
+// handler to list the orders of the logged in user
+func ShowOrders(c *gin.Context) {
+	orders, err := Orders.ByUsername(c.Request.Context(), c.GetString("username"))
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
+	render(c, gin.H{
+		"title":   "My Orders",
+		"payload": orders}, "orders.html")
+}
+
+// handler to show an order of the logged in user
+func GetOrder(c *gin.Context) {
+	order, ok := fetchOrder(c)
+	if !ok {
+		return
+	}
+	// Users can only see their own orders
+	if order.Username != c.GetString("username") {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	render(c, gin.H{
+		"title":   "Order #" + strconv.Itoa(order.ID),
+		"payload": order}, "order.html")
+}
+
+// handler to list the orders of every user
+func ShowAdminOrders(c *gin.Context) {
+	orders, err := Orders.All(c.Request.Context())
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
+	render(c, gin.H{
+		"title":   "All Orders",
+		"admin":   true,
+		"payload": orders}, "orders.html")
+}
+
+// handler to show any order along with the statuses it can be moved to
+func GetAdminOrder(c *gin.Context) {
+	if order, ok := fetchOrder(c); ok {
+		showAdminOrder(c, http.StatusOK, order, nil)
+	}
+}
+
+// handler to move an order to the POSTed status
+func UpdateOrderStatus(c *gin.Context) {
+	order, ok := fetchOrder(c)
+	if !ok {
+		return
+	}
+
+	updated, err := models.ChangeOrderStatus(c.Request.Context(), Orders, Payments, order.ID, c.PostForm("status"), c.GetString("username"))
+	switch err {
+	case nil:
+		showAdminOrder(c, http.StatusOK, updated, nil)
+	case models.ErrInvalidTransition:
+		// Show the order again with the reason the change was rejected
+		showAdminOrder(c, http.StatusConflict, order, err)
+	case models.ErrPaymentDeclined, models.ErrPaymentTimeout, models.ErrPaymentNotFound:
+		// The payment provider refused to refund the order
+		showAdminOrder(c, http.StatusBadGateway, order, err)
+	case models.ErrOrderNotFound:
+		c.AbortWithError(http.StatusNotFound, err)
+	default:
+		showServerError(c, err)
+	}
+}
+
+// handler to receive the notifications of the payment provider
+func PaymentWebhook(c *gin.Context) {
+	payload, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	event, err := Payments.VerifyWebhook(payload, c.GetHeader("X-Payment-Signature"))
+	if err == models.ErrWebhooksDisabled {
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusUnauthorized, err)
+		return
+	}
+
+	switch _, err := models.HandlePaymentEvent(c.Request.Context(), Orders, event); err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case models.ErrOrderNotFound:
+		c.AbortWithError(http.StatusNotFound, err)
+	case models.ErrInvalidTransition:
+		c.AbortWithError(http.StatusConflict, err)
+	default:
+		showServerError(c, err)
+	}
+}
+
+// Fetch the order whose ID is in the URL, aborting the request if it
+// can't be found
+func fetchOrder(c *gin.Context) (*models.Order, bool) {
+	orderID, err := strconv.Atoi(c.Param("order_id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	order, err := Orders.ByID(c.Request.Context(), orderID)
+	if err == models.ErrOrderNotFound {
+		c.AbortWithError(http.StatusNotFound, err)
+		return nil, false
+	} else if err != nil {
+		showServerError(c, err)
+		return nil, false
+	}
+	return order, true
+}
+
+// Render an order for the administrators
+func showAdminOrder(c *gin.Context, code int, order *models.Order, err error) {
+	data := gin.H{
+		"title":       "Order #" + strconv.Itoa(order.ID),
+		"admin":       true,
+		"transitions": models.NextOrderStatuses(order.Status),
+		"payload":     order}
+	if err == nil {
+		render(c, data, "order.html")
+		return
+	}
+	setPageData(c, data)
+	data["ErrorTitle"] = "Unable to change the status"
+	data["ErrorMessage"] = err.Error()
+	c.HTML(code, "order.html", data)
+}
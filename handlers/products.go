@@ -2,13 +2,172 @@ package handlers
 
 import (
 	"GolangStore/models"
+	"fmt"
+	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 func IndexPage(c *gin.Context) {
-	prod := models.ProductFinder()
+	prod, err := Products.All(c.Request.Context())
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
 	// Call the render function with the name of the template to render
 	render(c, gin.H{"title": "Home Page", "payload": prod}, "products.html")
-	//ctx.HTML(http.StatusOK, "products", models.ProductFinder())
+}
+
+func GetProduct(c *gin.Context) {
+	// Check if the product ID is valid
+	if productID, err := strconv.Atoi(c.Param("product_id")); err == nil {
+		// Check if the product exists
+		if product, err := Products.ByID(c.Request.Context(), productID); err == nil {
+			render(c, gin.H{
+				"title":   product.Name,
+				"payload": product}, "product.html")
+		} else if err == models.ErrProductNotFound {
+			c.AbortWithError(http.StatusNotFound, err)
+		} else {
+			showServerError(c, err)
+		}
+
+	} else {
+		// If an invalid product ID is specified in the URL, abort with an error
+		c.AbortWithStatus(http.StatusNotFound)
+	}
+}
+
+func ShowProductCreationPage(c *gin.Context) {
+	render(c, gin.H{
+		"title":  "Create New Product",
+		"action": "/product/create"}, "product-form.html")
+}
+
+func CreateProduct(c *gin.Context) {
+	p, err := productFromForm(c)
+	if err == nil {
+		err = p.Validate()
+	}
+	if err != nil {
+		// If the submitted values are invalid, show the form again with the error
+		showProductFormError(c, "Create New Product", "/product/create", p, err)
+		return
+	}
+
+	if err := Products.Create(c.Request.Context(), p); err == nil {
+		render(c, gin.H{
+			"title":   "Product Created",
+			"message": "The product was successfully created.",
+			"payload": p}, "product-successful.html")
+	} else {
+		showServerError(c, err)
+	}
+}
+
+func ShowProductEditPage(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if product, err := Products.ByID(c.Request.Context(), productID); err == nil {
+		render(c, gin.H{
+			"title":   "Edit Product",
+			"action":  "/product/edit/" + strconv.Itoa(product.Id),
+			"payload": product}, "product-form.html")
+	} else if err == models.ErrProductNotFound {
+		c.AbortWithError(http.StatusNotFound, err)
+	} else {
+		showServerError(c, err)
+	}
+}
+
+func UpdateProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	action := "/product/edit/" + strconv.Itoa(productID)
+	p, err := productFromForm(c)
+	if err == nil {
+		p.Id = productID
+		err = p.Validate()
+	}
+	if err != nil {
+		showProductFormError(c, "Edit Product", action, p, err)
+		return
+	}
+
+	if err := Products.Update(c.Request.Context(), p); err == nil {
+		render(c, gin.H{
+			"title":   "Product Updated",
+			"message": "The product was successfully updated.",
+			"payload": p}, "product-successful.html")
+	} else if err == models.ErrProductNotFound {
+		c.AbortWithError(http.StatusNotFound, err)
+	} else {
+		showServerError(c, err)
+	}
+}
+
+func DeleteProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	product, err := Products.ByID(c.Request.Context(), productID)
+	if err == nil {
+		err = Products.Delete(c.Request.Context(), productID)
+	}
+	if err == nil {
+		render(c, gin.H{
+			"title":   "Product Deleted",
+			"message": "The product was successfully deleted.",
+			"payload": product}, "product-successful.html")
+	} else if err == models.ErrProductNotFound {
+		c.AbortWithError(http.StatusNotFound, err)
+	} else {
+		showServerError(c, err)
+	}
+}
+
+// Build a product out of the POSTed form values
+func productFromForm(c *gin.Context) (*models.Product, error) {
+	p := &models.Product{
+		Name:        c.PostForm("name"),
+		Description: c.PostForm("description"),
+	}
+
+	var err error
+	if p.Price, err = strconv.ParseFloat(c.PostForm("price"), 64); err != nil {
+		return p, errInvalidField("price")
+	}
+	if p.Quantity, err = strconv.Atoi(c.PostForm("quantity")); err != nil {
+		return p, errInvalidField("quantity")
+	}
+	return p, nil
+}
+
+// Show the product form again along with the reason it was rejected
+func showProductFormError(c *gin.Context, title, action string, p *models.Product, err error) {
+	data := gin.H{
+		"title":        title,
+		"action":       action,
+		"payload":      p,
+		"ErrorTitle":   "Invalid Product",
+		"ErrorMessage": err.Error()}
+	setPageData(c, data)
+	c.HTML(http.StatusBadRequest, "product-form.html", data)
+}
+
+// Error returned when a POSTed form field can't be parsed
+func errInvalidField(name string) error {
+	return fmt.Errorf("the %s field is invalid", name)
 }
@@ -1,27 +1,64 @@
 package handlers
 
 import (
+	"GolangStore/models"
+	"bytes"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Render one of HTML, JSON or CSV based on the 'Accept' header of the request
+// Render one of HTML, JSON, XML or CSV based on the 'Accept' header of the
+// request, or on the format asked for in the URL (see negotiateFormat)
 // If the header doesn't specify this, HTML is rendered, provided that
-// the template name is present
+// the template name is present. When none of the formats is acceptable the
+// request is aborted with an HTTP not acceptable error
 func render(c *gin.Context, data gin.H, templateName string) {
-	loggedInInterface, _ := c.Get("is_logged_in")
-	data["is_logged_in"] = loggedInInterface.(bool)
+	setPageData(c, data)
 
-	switch c.Request.Header.Get("Accept") {
-	case "application/json":
+	format, ok := negotiateFormat(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusNotAcceptable)
+		return
+	}
+
+	switch format {
+	case formatJSON:
 		// Respond with JSON
 		c.JSON(http.StatusOK, data["payload"])
-	case "application/xml":
+	case formatXML:
 		// Respond with XML
 		c.XML(http.StatusOK, data["payload"])
+	case formatCSV:
+		// Respond with CSV, which is only possible for lists
+		renderCSV(c, data["payload"])
 	default:
 		// Respond with HTML
 		c.HTML(http.StatusOK, templateName, data)
 	}
 }
+
+// Set the values the templates need besides the payload. The pages shown
+// directly with c.HTML call this as well
+func setPageData(c *gin.Context, data gin.H) {
+	data["is_logged_in"] = c.GetBool("is_logged_in")
+	// Let the templates show the links matching the role of the user
+	role := c.GetString("role")
+	data["is_editor"] = role == models.RoleEditor || role == models.RoleAdmin
+	data["is_admin"] = role == models.RoleAdmin
+	// The forms send back the token set by the CSRF middleware
+	data["csrf_token"] = c.GetString("csrf_token")
+}
+
+// Write a list payload as CSV, or answer that it can't be represented so
+func renderCSV(c *gin.Context, payload interface{}) {
+	var buf bytes.Buffer
+	if err := models.WriteCSV(&buf, payload); err == models.ErrNotCSVList {
+		c.AbortWithError(http.StatusNotAcceptable, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
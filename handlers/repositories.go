@@ -0,0 +1,21 @@
+package handlers
+
+import "GolangStore/models"
+
+// The in-memory orders take the stock from the in-memory products
+var memoryProducts = models.NewMemoryProductRepository()
+
+// The storage the handlers work with. They default to in-memory backends so
+// that the handlers can be exercised without a database; GinSetup replaces
+// them with the Postgres backends before serving any request
+var (
+	Products models.ProductRepository = memoryProducts
+	Articles models.ArticleRepository = models.NewMemoryArticleRepository(models.DemoArticles...)
+	Users    models.UserRepository    = models.NewMemoryUserRepository(models.DemoUsers()...)
+	Sessions models.SessionStore      = models.NewMemorySessionStore()
+	Tokens   *models.TokenIssuer      = models.NewTokenIssuer(models.RandomTokenSecret(), models.NewMemoryRefreshTokenStore())
+	APIKeys  models.APIKeyRepository  = models.NewMemoryAPIKeyRepository()
+	Carts    models.CartRepository    = models.NewMemoryCartRepository()
+	Orders   models.OrderRepository   = models.NewMemoryOrderRepository(memoryProducts)
+	Payments models.PaymentGateway    = models.NewFakePaymentGateway(models.FakePaymentSucceed, "")
+)
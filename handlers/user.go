@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"GolangStore/middleware"
 	"GolangStore/models"
-	"math/rand"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// How long a user stays logged in
+const sessionTTL = time.Hour
+
 // handler to show the login page
 func ShowLoginPage(c *gin.Context) {
 	// Call the render function with the name of the template to render
@@ -24,11 +27,21 @@ func PerformLogin(c *gin.Context) {
 	password := c.PostForm("password")
 
 	// Check if the username/password combination is valid
-	if models.IsUserValid(username, password) {
-		// If the username/password is valid set the token in a cookie
-		token := GenerateSessionToken()
-		c.SetCookie("token", token, 3600, "", "", false, true)
-		c.Set("is_logged_in", true)
+	valid, err := models.IsUserValid(c.Request.Context(), Users, username, password)
+	if err != nil {
+		showServerError(c, err)
+		return
+	}
+	if valid {
+		// If the username/password is valid start a session and set its token in a cookie
+		user, err := Users.ByUsername(c.Request.Context(), username)
+		if err == nil {
+			err = startSession(c, user)
+		}
+		if err != nil {
+			showServerError(c, err)
+			return
+		}
 
 		render(c, gin.H{
 			"title": "Successful Login"}, "login-successful.html")
@@ -36,27 +49,57 @@ func PerformLogin(c *gin.Context) {
 	} else {
 		// If the username/password combination is invalid,
 		// show the error message on the login page
-		c.HTML(http.StatusBadRequest, "login.html", gin.H{
+		data := gin.H{
 			"ErrorTitle":   "Login Failed",
-			"ErrorMessage": "Invalid credentials provided"})
+			"ErrorMessage": "Invalid credentials provided"}
+		setPageData(c, data)
+		c.HTML(http.StatusBadRequest, "login.html", data)
 	}
 }
 
-func GenerateSessionToken() string {
-	// We're using a random 16 character string as the session token
-	// This is NOT a secure way of generating session tokens
-	// DO NOT USE THIS IN PRODUCTION
-	return strconv.FormatInt(rand.Int63(), 16)
+// Start a session for the user and hand its token, along with a new CSRF
+// token, to the browser. The cart filled before logging in is kept
+func startSession(c *gin.Context, user *models.User) error {
+	session, err := Sessions.Create(c.Request.Context(), user.Username, sessionTTL)
+	if err != nil {
+		return err
+	}
+	if err := mergeAnonymousCart(c, user.Username); err != nil {
+		return err
+	}
+	if err := middleware.RotateCSRF(c, SecureCookies, CookieSameSite); err != nil {
+		return err
+	}
+	setCookie(c, "token", session.Token, int(sessionTTL.Seconds()))
+	c.Set("is_logged_in", true)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	return nil
 }
 
 // handler to handle the logout request
 func Logout(c *gin.Context) {
-	c.SetCookie("token", "", -1, "", "", false, true)
+	if err := endSession(c); err != nil {
+		showServerError(c, err)
+		return
+	}
 
 	// Redirect to the home page
 	c.Redirect(http.StatusTemporaryRedirect, "/")
 }
 
+// End the session so that the token can't be used anymore, clear its cookie
+// and hand the browser a new CSRF token
+func endSession(c *gin.Context) error {
+	if token, err := c.Cookie("token"); err == nil {
+		if err := Sessions.Delete(c.Request.Context(), token); err != nil {
+			return err
+		}
+	}
+	setCookie(c, "token", "", -1)
+	return middleware.RotateCSRF(c, SecureCookies, CookieSameSite)
+}
+
 func ShowRegistrationPage(c *gin.Context) {
 	// Call the render function with the name of the template to render
 	render(c, gin.H{
@@ -70,22 +113,29 @@ func Register(c *gin.Context) {
 
 	//var sameSiteCookie http.SameSite
 
-	if _, err := models.RegisterNewUser(username, password); err == nil {
-		// If the user is created, set the token in a cookie and log the user in
-		token := GenerateSessionToken()
-		//c.SetCookie("token", token, 3600, "", "", sameSiteCookie, false, true)
-		c.SetCookie("token", token, 3600, "", "", false, true)
-		c.Set("is_logged_in", true)
+	user, err := models.RegisterNewUser(c.Request.Context(), Users, username, password)
+	switch err {
+	case nil:
+		// If the user is created, start a session and log the user in
+		if err := startSession(c, user); err != nil {
+			showServerError(c, err)
+			return
+		}
 
 		render(c, gin.H{
 			"title": "Successful registration & Login"}, "login-successful.html")
 
-	} else {
+	case models.ErrUsernameTaken, models.ErrEmptyPassword:
 		// If the username/password combination is invalid,
 		// show the error message on the login page
-		c.HTML(http.StatusBadRequest, "register.html", gin.H{
+		data := gin.H{
 			"ErrorTitle":   "Registration Failed",
-			"ErrorMessage": err.Error()})
+			"ErrorMessage": err.Error()}
+		setPageData(c, data)
+		c.HTML(http.StatusBadRequest, "register.html", data)
 
+	default:
+		// Anything else isn't the user's fault and isn't shown to them
+		showServerError(c, err)
 	}
 }
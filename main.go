@@ -1,10 +1,28 @@
 package main
 
 import (
-	"GolangStore/routes"
+	"GolangStore/cli"
+	"GolangStore/config"
+	"log"
+	"os"
 )
 
 func main() {
-	// Setup Gin-Gonic
-	routes.GinSetup()
+	// Print the usage even when the configuration is invalid
+	if cli.Help(os.Args[1:]) {
+		return
+	}
+
+	// Read the configuration file and the environment
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Run the command, serving the application by default
+	if err := cli.Run(cfg, os.Args[1:]); err == cli.ErrUsage {
+		os.Exit(2)
+	} else if err != nil {
+		log.Fatal(err)
+	}
 }
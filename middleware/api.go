@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Body of the API responses reporting an error
+type APIError struct {
+	Error APIErrorBody `json:"error"`
+}
+
+// The machine readable code of an API error along with a message for humans
+type APIErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// The codes of the API errors
+const (
+	ErrCodeBadRequest   = "bad_request"
+	ErrCodeUnauthorized = "unauthorized"
+	ErrCodeForbidden    = "forbidden"
+	ErrCodeNotFound     = "not_found"
+	ErrCodeNotAllowed   = "method_not_allowed"
+	ErrCodeConflict     = "conflict"
+	ErrCodeValidation   = "validation_failed"
+	ErrCodeInternal     = "internal_error"
+	ErrCodeUnavailable  = "unavailable"
+	ErrCodeTimeout      = "timeout"
+)
+
+// The code used when an API request is aborted with nothing but a status
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:          ErrCodeBadRequest,
+	http.StatusUnauthorized:        ErrCodeUnauthorized,
+	http.StatusForbidden:           ErrCodeForbidden,
+	http.StatusNotFound:            ErrCodeNotFound,
+	http.StatusMethodNotAllowed:    ErrCodeNotAllowed,
+	http.StatusConflict:            ErrCodeConflict,
+	http.StatusUnprocessableEntity: ErrCodeValidation,
+	http.StatusInternalServerError: ErrCodeInternal,
+	http.StatusServiceUnavailable:  ErrCodeUnavailable,
+}
+
+/* marks the request as an API one, so that the other middlewares report
+their errors with a JSON body instead of a bare status. */
+// This middleware must come first on the API routes
+func API() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("api", true)
+	}
+}
+
+/* marks the requests whose path starts with the prefix as API ones, like
+API does. It's meant to run before every other middleware, so that the
+requests they reject, or that match no route, get a JSON error body too. */
+// This middleware marks the API requests from the path
+func APIPrefix(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if path := c.Request.URL.Path; path == prefix || strings.HasPrefix(path, prefix+"/") {
+			c.Set("api", true)
+		}
+	}
+}
+
+// Answer the API requests that match no route, or no method of a route,
+// with the status and a JSON error body. The other requests get the
+// default answer of gin
+func APIFallback(status int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("api") {
+			abortWithStatus(c, status)
+		}
+	}
+}
+
+// Abort an API request with the given status and a JSON error body
+func AbortWithAPIError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, APIError{Error: APIErrorBody{Code: code, Message: message}})
+}
+
+// Abort the request with the given status, describing it with a JSON error
+// body when it's an API request
+func abortWithStatus(c *gin.Context, status int) {
+	if !c.GetBool("api") {
+		c.AbortWithStatus(status)
+		return
+	}
+	code, ok := statusErrorCodes[status]
+	if !ok {
+		code = ErrCodeBadRequest
+	}
+	AbortWithAPIError(c, status, code, http.StatusText(status))
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"GolangStore/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* checks for an API key in the X-API-Key header. A valid key logs its owner
+in for the request, limited to the scopes of the key which RequireScope
+checks, and its last use is recorded. An unknown key aborts the request with
+an HTTP unauthorized error. */
+// This middleware lets the integrations authenticate with an API key
+func SetAPIKeyUser(keys models.APIKeyRepository, users models.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plain := c.GetHeader("X-API-Key")
+		if plain == "" {
+			return
+		}
+
+		key, err := models.UseAPIKey(c.Request.Context(), keys, plain)
+		var user *models.User
+		if err == nil {
+			user, err = users.ByUsername(c.Request.Context(), key.Username)
+		}
+		if err == models.ErrAPIKeyNotFound || err == models.ErrUserNotFound {
+			abortWithStatus(c, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			c.Error(err)
+			abortWithStatus(c, http.StatusInternalServerError)
+			return
+		}
+
+		c.Set("is_logged_in", true)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("api_key", key)
+	}
+}
+
+/* checks the scopes of the API key set by SetAPIKeyUser. The users logged in
+any other way aren't limited by scopes and get through. */
+// This middleware ensures that an API key was granted the scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := c.Get("api_key"); ok && !key.(*models.APIKey).HasScope(scope) {
+			abortWithStatus(c, http.StatusForbidden)
+		}
+	}
+}
+
+// This middleware keeps the API keys away from the routes they must never
+// reach, like the ones managing the keys themselves
+func EnsureNotAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key"); ok {
+			abortWithStatus(c, http.StatusForbidden)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"GolangStore/models"
+	"context"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,7 +22,7 @@ func EnsureLoggedIn() gin.HandlerFunc {
 		loggedIn := loggedInInterface.(bool)
 		if !loggedIn {
 			//if token, err := c.Cookie("token"); err != nil || token == "" {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			abortWithStatus(c, http.StatusUnauthorized)
 		}
 	}
 }
@@ -37,20 +40,98 @@ func EnsureNotLoggedIn() gin.HandlerFunc {
 		loggedIn := loggedInInterface.(bool)
 		if loggedIn {
 			// if token, err := c.Cookie("token"); err == nil || token != "" {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			abortWithStatus(c, http.StatusUnauthorized)
 		}
 	}
 }
 
-/* checks for the token cookie in the the context and sets the is_logged_in
-flag based on that. */
+/* checks for a bearer token in the Authorization header, or else for the
+token cookie in the the context, and sets the is_logged_in flag based on that.
+A bearer token must have been issued by the token issuer and not revoked, a
+cookie token must belong to a session of the store that hasn't expired; the
+username and role of its owner are set in the context as well, along with
+the bearer token as access_token. */
 // This middleware sets whether the user is logged in or not
-func SetUserStatus() gin.HandlerFunc {
+func SetUserStatus(sessions models.SessionStore, tokens *models.TokenIssuer, users models.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if token, err := c.Cookie("token"); err == nil || token != "" {
-			c.Set("is_logged_in", true)
+		var user *models.User
+		var err error
+		if bearer, ok := bearerToken(c); ok {
+			user, err = tokenUser(c.Request.Context(), tokens, users, bearer)
+		} else if token, cookieErr := c.Cookie("token"); cookieErr == nil && token != "" {
+			user, err = sessionUser(c.Request.Context(), sessions, users, token)
 		} else {
-			c.Set("is_logged_in", false)
+			err = models.ErrSessionNotFound
+		}
+
+		if err == nil {
+			if bearer, ok := bearerToken(c); ok {
+				c.Set("access_token", bearer)
+			}
+			c.Set("is_logged_in", true)
+			c.Set("username", user.Username)
+			c.Set("role", user.Role)
+			return
+		} else if !isAuthError(err) {
+			c.Error(err)
 		}
+		c.Set("is_logged_in", false)
+	}
+}
+
+/* checks the role set by SetUserStatus. If the user isn't logged in the
+request is aborted with an HTTP unauthorized error, if the user doesn't have
+one of the given roles with an HTTP forbidden error. */
+// This middleware ensures that only users having one of the roles get through
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("is_logged_in") {
+			abortWithStatus(c, http.StatusUnauthorized)
+			return
+		}
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				return
+			}
+		}
+		abortWithStatus(c, http.StatusForbidden)
+	}
+}
+
+// Return the user owning the session of the token
+func sessionUser(ctx context.Context, sessions models.SessionStore, users models.UserRepository, token string) (*models.User, error) {
+	session, err := sessions.Get(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return users.ByUsername(ctx, session.Username)
+}
+
+// Return the user owning the access token
+func tokenUser(ctx context.Context, tokens *models.TokenIssuer, users models.UserRepository, token string) (*models.User, error) {
+	username, err := tokens.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return users.ByUsername(ctx, username)
+}
+
+// Return the token of an 'Authorization: Bearer' header
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// Check whether the error only means that the credentials aren't valid
+func isAuthError(err error) bool {
+	switch err {
+	case models.ErrSessionNotFound, models.ErrUserNotFound, models.ErrInvalidToken,
+		models.ErrTokenExpired, models.ErrTokenRevoked:
+		return true
 	}
+	return false
 }
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"GolangStore/models"
+	"crypto/subtle"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Where the CSRF token is kept by the browser and where it's sent back
+const (
+	csrfCookie = "csrf"
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+/* hands every visitor a random token in the csrf cookie and sets it in the
+context as csrf_token, so that the pages can put it in their forms. Requests
+with an unsafe method must send the token back, either as the csrf_token form
+field or in the X-CSRF-Token header, or they are aborted with an HTTP forbidden
+error. Another site can read neither the cookie nor the pages, so it can't
+forge such a request. The token changes whenever a user logs in or out, see
+RotateCSRF. The requests that can't be forged from another site, like JSON
+ones or ones authenticated with a header, aren't checked, nor are the exempt
+paths, such as webhooks signed by their sender. */
+// This middleware protects the HTML forms against cross-site request forgery
+func CSRF(secure bool, sameSite http.SameSite, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := c.Cookie(csrfCookie)
+		if err != nil || len(token) != 64 {
+			if token, err = newCSRFToken(c, secure, sameSite); err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		c.Set("csrf_token", token)
+
+		if !needsCSRFCheck(c, exempt) {
+			return
+		}
+		submitted := c.GetHeader(csrfHeader)
+		if submitted == "" {
+			submitted = c.PostForm(csrfField)
+		}
+		if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			abortWithStatus(c, http.StatusForbidden)
+		}
+	}
+}
+
+// Hand the browser a new CSRF token, so that one captured before a user logs
+// in or out can't be used afterwards. The pages rendered by the request get
+// the new one
+func RotateCSRF(c *gin.Context, secure bool, sameSite http.SameSite) error {
+	token, err := newCSRFToken(c, secure, sameSite)
+	if err != nil {
+		return err
+	}
+	c.Set("csrf_token", token)
+	return nil
+}
+
+// Generate a CSRF token and set it in the csrf cookie
+func newCSRFToken(c *gin.Context, secure bool, sameSite http.SameSite) (string, error) {
+	token, err := models.NewSessionToken()
+	if err != nil {
+		return "", err
+	}
+	c.SetSameSite(sameSite)
+	c.SetCookie(csrfCookie, token, 0, "", "", secure, true)
+	return token, nil
+}
+
+// Check whether the request could have been forged by another site
+func needsCSRFCheck(c *gin.Context, exempt []string) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	for _, path := range exempt {
+		if c.Request.URL.Path == path {
+			return false
+		}
+	}
+	// Browsers only send these from another site after a CORS preflight,
+	// which the application never allows
+	if _, ok := bearerToken(c); ok || c.GetHeader("X-API-Key") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	return mediaType != "application/json"
+}
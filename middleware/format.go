@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The URL suffixes selecting the format of the response
+var formatSuffixes = []string{".json", ".xml", ".csv", ".html"}
+
+/* strips a format suffix such as .json from the path of the request and
+passes it on as the format query parameter, so that /article/view/1.json
+reaches the /article/view/:article_id route. It has to wrap the router
+itself since the path must be changed before the route is chosen. Routes
+whose path really ends with a suffix, like /openapi.json, are left alone. */
+// This handler turns the format suffix of the URLs into a query parameter
+func FormatSuffix(router *gin.Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, suffix := range formatSuffixes {
+			if strings.HasSuffix(r.URL.Path, suffix) && len(r.URL.Path) > len(suffix)+1 &&
+				!isStaticRoute(router, r.Method, r.URL.Path) {
+				r.URL.Path = strings.TrimSuffix(r.URL.Path, suffix)
+				r.URL.RawPath = ""
+				query := r.URL.Query()
+				if query.Get("format") == "" {
+					query.Set("format", strings.TrimPrefix(suffix, "."))
+				}
+				r.URL.RawQuery = query.Encode()
+				break
+			}
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+// Check whether a route was registered with exactly this path
+func isStaticRoute(router *gin.Engine, method, path string) bool {
+	for _, route := range router.Routes() {
+		if route.Method == method && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
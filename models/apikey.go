@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// A long lived credential a user hands to an integration. It only grants
+// the listed scopes, and the key itself is only known when it's created
+type APIKey struct {
+	ID       int    `json:"id"`
+	Username string `json:"-"`
+	Name     string `json:"name"`
+	// The plain key, only set right after creation
+	Key string `json:"key,omitempty"`
+	// The beginning of the key, to tell the keys apart
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// The scopes an API key can be granted
+const (
+	ScopeReadArticles  = "read:articles"
+	ScopeWriteArticles = "write:articles"
+	ScopeReadProducts  = "read:products"
+	ScopeWriteProducts = "write:products"
+	ScopeReadProfile   = "read:profile"
+)
+
+var apiKeyScopes = []string{ScopeReadArticles, ScopeWriteArticles, ScopeReadProducts, ScopeWriteProducts, ScopeReadProfile}
+
+// Every API key starts with this, so that leaked keys are easy to spot
+const apiKeyPrefix = "gsk_"
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidAPIKey  = errors.New("the API key needs a name and at least one valid scope")
+)
+
+// The storage keeping the API keys, hashed
+type APIKeyRepository interface {
+	// Store a new key, setting its ID and creation time
+	Create(ctx context.Context, key *APIKey, keyHash string) error
+	// Return the keys of a user, oldest first
+	ByUser(ctx context.Context, username string) ([]APIKey, error)
+	// Fetch the key matching a hash, failing with ErrAPIKeyNotFound if there's none
+	ByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	// Remove a key of the user, failing with ErrAPIKeyNotFound if the user
+	// has no such key
+	Delete(ctx context.Context, username string, id int) error
+	// Record that the key was just used
+	Touch(ctx context.Context, id int, at time.Time) error
+}
+
+// Check whether the scope is one of the known ones
+func IsValidScope(scope string) bool {
+	for _, s := range apiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the scopes an API key can be granted
+func APIKeyScopes() []string {
+	return append([]string{}, apiKeyScopes...)
+}
+
+// Create an API key for the user. The returned key holds the plain key,
+// which can't be recovered afterwards
+func CreateAPIKey(ctx context.Context, keys APIKeyRepository, username, name string, scopes []string) (*APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(scopes) == 0 {
+		return nil, ErrInvalidAPIKey
+	}
+	for _, scope := range scopes {
+		if !IsValidScope(scope) {
+			return nil, ErrInvalidAPIKey
+		}
+	}
+
+	token, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	plain := apiKeyPrefix + token
+	key := APIKey{
+		Username: username,
+		Name:     name,
+		Key:      plain,
+		Prefix:   plain[:len(apiKeyPrefix)+8],
+		Scopes:   append([]string{}, scopes...),
+	}
+	if err := keys.Create(ctx, &key, hashToken(plain)); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Return the API key matching a plain key and record its use
+func UseAPIKey(ctx context.Context, keys APIKeyRepository, plain string) (*APIKey, error) {
+	if !strings.HasPrefix(plain, apiKeyPrefix) {
+		return nil, ErrAPIKeyNotFound
+	}
+	key, err := keys.ByHash(ctx, hashToken(plain))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := keys.Touch(ctx, key.ID, now); err != nil {
+		return nil, err
+	}
+	key.LastUsedAt = &now
+	return key, nil
+}
+
+// Check whether the key was granted the scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// API key repository keeping the keys in memory
+type MemoryAPIKeyRepository struct {
+	mu     sync.Mutex
+	keys   []APIKey
+	hashes map[int]string
+	nextID int
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{hashes: map[int]string{}, nextID: 1}
+}
+
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *APIKey, keyHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key.ID = r.nextID
+	r.nextID++
+	key.CreatedAt = time.Now()
+
+	stored := *key
+	stored.Key = ""
+	stored.Scopes = append([]string{}, key.Scopes...)
+	r.keys = append(r.keys, stored)
+	r.hashes[key.ID] = keyHash
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) ByUser(ctx context.Context, username string) ([]APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := []APIKey{}
+	for _, k := range r.keys {
+		if k.Username == username {
+			keys = append(keys, copyAPIKey(k))
+		}
+	}
+	return keys, nil
+}
+
+func (r *MemoryAPIKeyRepository) ByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.keys {
+		if r.hashes[k.ID] == keyHash {
+			key := copyAPIKey(k)
+			return &key, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (r *MemoryAPIKeyRepository) Delete(ctx context.Context, username string, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, k := range r.keys {
+		if k.ID == id && k.Username == username {
+			r.keys = append(r.keys[:i], r.keys[i+1:]...)
+			delete(r.hashes, id)
+			return nil
+		}
+	}
+	return ErrAPIKeyNotFound
+}
+
+func (r *MemoryAPIKeyRepository) Touch(ctx context.Context, id int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.keys {
+		if r.keys[i].ID == id {
+			r.keys[i].LastUsedAt = &at
+			return nil
+		}
+	}
+	return ErrAPIKeyNotFound
+}
+
+// Copy a key so that the caller can't change the stored scopes
+func copyAPIKey(k APIKey) APIKey {
+	k.Scopes = append([]string{}, k.Scopes...)
+	return k
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// API key repository backed by the api_keys table
+type PostgresAPIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAPIKeyRepository(db *sql.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{db: db}
+}
+
+func (r *PostgresAPIKeyRepository) Create(ctx context.Context, key *APIKey, keyHash string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err := r.db.QueryRowContext(ctx, `insert into api_keys (username, name, key_hash, prefix, scopes)
+		values ($1, $2, $3, $4, $5) returning id, created_at`,
+		key.Username, key.Name, keyHash, key.Prefix, pq.Array(key.Scopes)).Scan(&key.ID, &key.CreatedAt)
+	return dbError(ctx, err)
+}
+
+func (r *PostgresAPIKeyRepository) ByUser(ctx context.Context, username string) ([]APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, `select id, username, name, prefix, scopes, created_at, last_used_at
+		from api_keys where username = $1 order by id`, username)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, dbError(ctx, rows.Err())
+}
+
+func (r *PostgresAPIKeyRepository) ByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, `select id, username, name, prefix, scopes, created_at, last_used_at
+		from api_keys where key_hash = $1`, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, dbError(ctx, err)
+}
+
+func (r *PostgresAPIKeyRepository) Delete(ctx context.Context, username string, id int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := r.db.ExecContext(ctx, "delete from api_keys where id = $1 and username = $2", id, username)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	return requireAffected(res, ErrAPIKeyNotFound)
+}
+
+func (r *PostgresAPIKeyRepository) Touch(ctx context.Context, id int, at time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, "update api_keys set last_used_at = $1 where id = $2", at, id)
+	return dbError(ctx, err)
+}
+
+// Read a key out of a row holding the columns selected above
+func scanAPIKey(row interface {
+	Scan(dest ...interface{}) error
+}) (*APIKey, error) {
+	var key APIKey
+	var lastUsed sql.NullTime
+	err := row.Scan(&key.ID, &key.Username, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	return &key, nil
+}
@@ -1,39 +1,42 @@
 package models
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"strings"
+)
 
 type Article struct {
-	ID      int    `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	ID      int    `json:"id" csv:"id"`
+	Title   string `json:"title" csv:"title"`
+	Content string `json:"content" csv:"content"`
 }
 
-// For this demo, we're storing the article list in memory
-// In a real application, this list will most likely be fetched
-// from a database or from static files
-var ArticleList = []Article{
+var ErrArticleNotFound = errors.New("article not found")
+
+// The articles shown on the home page of a fresh installation
+var DemoArticles = []Article{
 	{ID: 1, Title: "Article 1", Content: "Article 1 body"},
 	{ID: 2, Title: "Article 2", Content: "Article 2 body"},
 }
 
-// Return a list of all the articles
-func GetAllArticles() []Article {
-	return ArticleList
+// The storage backing the articles
+type ArticleRepository interface {
+	// Return a list of all the articles
+	All(ctx context.Context) ([]Article, error)
+	// Fetch a single article by its ID
+	ByID(ctx context.Context, id int) (*Article, error)
+	// Store a new article, setting its ID
+	Create(ctx context.Context, a *Article) error
 }
 
-func GetArticleByID(id int) (*Article, error) {
-	for _, a := range ArticleList {
-		if a.ID == id {
-			return &a, nil
-		}
+// Check that the article has a title and some content
+func (a *Article) Validate() error {
+	a.Title = strings.TrimSpace(a.Title)
+	if a.Title == "" {
+		return errors.New("the article title can't be empty")
+	} else if strings.TrimSpace(a.Content) == "" {
+		return errors.New("the article content can't be empty")
 	}
-	return nil, errors.New("article not found")
-}
-
-func CreateNewArticle(title, content string) (*Article, error) {
-	a := Article{ID: len(ArticleList) + 1, Title: title, Content: content}
-
-	ArticleList = append(ArticleList, a)
-
-	return &a, nil
+	return nil
 }
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"sync"
+)
+
+// Article repository keeping the articles in memory
+type MemoryArticleRepository struct {
+	mu       sync.RWMutex
+	articles []Article
+	nextID   int
+}
+
+// Create a repository holding a copy of the given articles
+func NewMemoryArticleRepository(articles ...Article) *MemoryArticleRepository {
+	r := &MemoryArticleRepository{nextID: 1}
+	for _, a := range articles {
+		r.articles = append(r.articles, a)
+		if a.ID >= r.nextID {
+			r.nextID = a.ID + 1
+		}
+	}
+	return r
+}
+
+func (r *MemoryArticleRepository) All(ctx context.Context) ([]Article, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	articles := make([]Article, len(r.articles))
+	copy(articles, r.articles)
+	return articles, nil
+}
+
+func (r *MemoryArticleRepository) ByID(ctx context.Context, id int) (*Article, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, a := range r.articles {
+		if a.ID == id {
+			return &a, nil
+		}
+	}
+	return nil, ErrArticleNotFound
+}
+
+func (r *MemoryArticleRepository) Create(ctx context.Context, a *Article) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a.ID = r.nextID
+	r.nextID++
+	r.articles = append(r.articles, *a)
+	return nil
+}
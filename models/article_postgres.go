@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Article repository backed by the articles table
+type PostgresArticleRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresArticleRepository(db *sql.DB) *PostgresArticleRepository {
+	return &PostgresArticleRepository{db: db}
+}
+
+func (r *PostgresArticleRepository) All(ctx context.Context) ([]Article, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, "select id, title, content from articles order by id")
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+	articles := []Article{}
+	for rows.Next() {
+		a := Article{}
+		if err := rows.Scan(&a.ID, &a.Title, &a.Content); err != nil {
+			return nil, dbError(ctx, err)
+		}
+		articles = append(articles, a)
+	}
+	return articles, dbError(ctx, rows.Err())
+}
+
+func (r *PostgresArticleRepository) ByID(ctx context.Context, id int) (*Article, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	a := Article{}
+	err := r.db.QueryRowContext(ctx, "select id, title, content from articles where id = $1", id).
+		Scan(&a.ID, &a.Title, &a.Content)
+	if err == sql.ErrNoRows {
+		return nil, ErrArticleNotFound
+	} else if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &a, nil
+}
+
+func (r *PostgresArticleRepository) Create(ctx context.Context, a *Article) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err := r.db.QueryRowContext(ctx, "insert into articles (title, content) values ($1, $2) returning id",
+		a.Title, a.Content).Scan(&a.ID)
+	return dbError(ctx, err)
+}
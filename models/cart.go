@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+	"errors"
+)
+
+// A product put in a cart, along with the wanted quantity
+type CartItem struct {
+	Product  Product `json:"product"`
+	Quantity int     `json:"quantity"`
+}
+
+// The products a visitor intends to buy
+type Cart struct {
+	Items []CartItem `json:"items"`
+	Total float64    `json:"total"`
+}
+
+// The stored form of a cart item, which only references the product
+type CartEntry struct {
+	ProductID int
+	Quantity  int
+}
+
+var (
+	ErrInsufficientStock = errors.New("there isn't enough stock for the requested quantity")
+	ErrInvalidQuantity   = errors.New("the quantity must be positive")
+)
+
+// The storage backing the carts. A cart belongs to an owner, which is either
+// an anonymous visitor or a logged in user (see AnonymousCartOwner and
+// UserCartOwner)
+type CartRepository interface {
+	// Return the entries of the owner's cart
+	Entries(ctx context.Context, owner string) ([]CartEntry, error)
+	// Set the quantity of a product in the owner's cart, adding it if needed
+	SetQuantity(ctx context.Context, owner string, productID, quantity int) error
+	// Take a product out of the owner's cart
+	Remove(ctx context.Context, owner string, productID int) error
+	// Empty the owner's cart and return the entries it held, at once so
+	// that two checkouts of the same cart can't both get them
+	Take(ctx context.Context, owner string) ([]CartEntry, error)
+	// Move the entries of a cart into another one, adding up the quantities
+	// of the products found in both
+	Merge(ctx context.Context, from, to string) error
+}
+
+// The owner of the cart of a visitor that isn't logged in
+func AnonymousCartOwner(id string) string {
+	return "anonymous:" + id
+}
+
+// The owner of the cart of a logged in user
+func UserCartOwner(username string) string {
+	return "user:" + username
+}
+
+// Return the owner's cart along with the details of its products. Products
+// that were removed from the catalog are left out
+func LoadCart(ctx context.Context, carts CartRepository, products ProductRepository, owner string) (*Cart, error) {
+	entries, err := carts.Entries(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	cart := &Cart{Items: []CartItem{}}
+	for _, e := range entries {
+		p, err := products.ByID(ctx, e.ProductID)
+		if err == ErrProductNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		cart.Items = append(cart.Items, CartItem{Product: *p, Quantity: e.Quantity})
+		cart.Total += p.Price * float64(e.Quantity)
+	}
+	return cart, nil
+}
+
+// Add some units of a product to the owner's cart, as long as there's
+// enough stock for the resulting quantity
+func AddToCart(ctx context.Context, carts CartRepository, products ProductRepository, owner string, productID, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	entries, err := carts.Entries(ctx, owner)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.ProductID == productID {
+			quantity += e.Quantity
+		}
+	}
+	return UpdateCartQuantity(ctx, carts, products, owner, productID, quantity)
+}
+
+// Change the quantity of a product in the owner's cart, as long as there's
+// enough stock for it. A quantity of zero takes the product out of the cart
+func UpdateCartQuantity(ctx context.Context, carts CartRepository, products ProductRepository, owner string, productID, quantity int) error {
+	if quantity < 0 {
+		return ErrInvalidQuantity
+	} else if quantity == 0 {
+		return carts.Remove(ctx, owner, productID)
+	}
+
+	p, err := products.ByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if quantity > p.Quantity {
+		return ErrInsufficientStock
+	}
+	return carts.SetQuantity(ctx, owner, productID, quantity)
+}
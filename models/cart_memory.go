@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"sync"
+)
+
+// Cart repository keeping the carts in memory
+type MemoryCartRepository struct {
+	mu    sync.Mutex
+	carts map[string][]CartEntry
+}
+
+func NewMemoryCartRepository() *MemoryCartRepository {
+	return &MemoryCartRepository{carts: map[string][]CartEntry{}}
+}
+
+func (r *MemoryCartRepository) Entries(ctx context.Context, owner string) ([]CartEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CartEntry{}, r.carts[owner]...), nil
+}
+
+func (r *MemoryCartRepository) SetQuantity(ctx context.Context, owner string, productID, quantity int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setQuantity(owner, productID, quantity)
+	return nil
+}
+
+func (r *MemoryCartRepository) Remove(ctx context.Context, owner string, productID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.carts[owner]
+	for i, e := range entries {
+		if e.ProductID == productID {
+			r.carts[owner] = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *MemoryCartRepository) Take(ctx context.Context, owner string) ([]CartEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := append([]CartEntry{}, r.carts[owner]...)
+	delete(r.carts, owner)
+	return entries, nil
+}
+
+func (r *MemoryCartRepository) Merge(ctx context.Context, from, to string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, moved := range r.carts[from] {
+		quantity := moved.Quantity
+		for _, e := range r.carts[to] {
+			if e.ProductID == moved.ProductID {
+				quantity += e.Quantity
+			}
+		}
+		r.setQuantity(to, moved.ProductID, quantity)
+	}
+	delete(r.carts, from)
+	return nil
+}
+
+// Callers must hold the lock
+func (r *MemoryCartRepository) setQuantity(owner string, productID, quantity int) {
+	entries := r.carts[owner]
+	for i := range entries {
+		if entries[i].ProductID == productID {
+			entries[i].Quantity = quantity
+			return
+		}
+	}
+	r.carts[owner] = append(entries, CartEntry{ProductID: productID, Quantity: quantity})
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Cart repository backed by the cart_items table
+type PostgresCartRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCartRepository(db *sql.DB) *PostgresCartRepository {
+	return &PostgresCartRepository{db: db}
+}
+
+func (r *PostgresCartRepository) Entries(ctx context.Context, owner string) ([]CartEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, "select product_id, quantity from cart_items where owner = $1 order by added_at", owner)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+	entries := []CartEntry{}
+	for rows.Next() {
+		e := CartEntry{}
+		if err := rows.Scan(&e.ProductID, &e.Quantity); err != nil {
+			return nil, dbError(ctx, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, dbError(ctx, rows.Err())
+}
+
+func (r *PostgresCartRepository) SetQuantity(ctx context.Context, owner string, productID, quantity int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, `insert into cart_items (owner, product_id, quantity) values ($1, $2, $3)
+		on conflict (owner, product_id) do update set quantity = excluded.quantity`,
+		owner, productID, quantity)
+	return dbError(ctx, err)
+}
+
+func (r *PostgresCartRepository) Remove(ctx context.Context, owner string, productID int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, "delete from cart_items where owner = $1 and product_id = $2", owner, productID)
+	return dbError(ctx, err)
+}
+
+func (r *PostgresCartRepository) Take(ctx context.Context, owner string) ([]CartEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, "delete from cart_items where owner = $1 returning product_id, quantity", owner)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+	entries := []CartEntry{}
+	for rows.Next() {
+		e := CartEntry{}
+		if err := rows.Scan(&e.ProductID, &e.Quantity); err != nil {
+			return nil, dbError(ctx, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, dbError(ctx, rows.Err())
+}
+
+func (r *PostgresCartRepository) Merge(ctx context.Context, from, to string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `insert into cart_items (owner, product_id, quantity, added_at)
+		select $2, product_id, quantity, added_at from cart_items where owner = $1
+		on conflict (owner, product_id) do update set quantity = cart_items.quantity + excluded.quantity`,
+		from, to)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	if _, err = tx.ExecContext(ctx, "delete from cart_items where owner = $1", from); err != nil {
+		return dbError(ctx, err)
+	}
+	return dbError(ctx, tx.Commit())
+}
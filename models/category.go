@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// A group of products of the catalog
+type Category struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	ProductIDs []int  `json:"product_ids"`
+}
+
+var ErrCategoryExists = errors.New("a category with this name already exists")
+
+// The storage backing the categories
+type CategoryRepository interface {
+	// Return all the categories, by name
+	All(ctx context.Context) ([]Category, error)
+	// Store a new category along with its products, setting its ID. Fails
+	// with ErrCategoryExists when the name is taken
+	Create(ctx context.Context, c *Category) error
+}
+
+// Check that the category has a name
+func (c *Category) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("the category name can't be empty")
+	}
+	return nil
+}
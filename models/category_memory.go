@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Category repository keeping the categories in memory
+type MemoryCategoryRepository struct {
+	mu         sync.RWMutex
+	categories []Category
+	nextID     int
+}
+
+func NewMemoryCategoryRepository() *MemoryCategoryRepository {
+	return &MemoryCategoryRepository{nextID: 1}
+}
+
+func (r *MemoryCategoryRepository) All(ctx context.Context) ([]Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	categories := []Category{}
+	for _, c := range r.categories {
+		c.ProductIDs = append([]int{}, c.ProductIDs...)
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (r *MemoryCategoryRepository) Create(ctx context.Context, c *Category) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.categories {
+		if existing.Name == c.Name {
+			return ErrCategoryExists
+		}
+	}
+	c.ID = r.nextID
+	r.nextID++
+	stored := *c
+	stored.ProductIDs = append([]int{}, c.ProductIDs...)
+	sort.Ints(stored.ProductIDs)
+	r.categories = append(r.categories, stored)
+	return nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// Category repository backed by the categories and product_categories tables
+type PostgresCategoryRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCategoryRepository(db *sql.DB) *PostgresCategoryRepository {
+	return &PostgresCategoryRepository{db: db}
+}
+
+func (r *PostgresCategoryRepository) All(ctx context.Context) ([]Category, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, `select c.id, c.name,
+			coalesce(array_agg(pc.product_id order by pc.product_id) filter (where pc.product_id is not null), '{}')
+		from categories c left join product_categories pc on pc.category_id = c.id
+		group by c.id order by c.name`)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var c Category
+		var productIDs []int64
+		if err := rows.Scan(&c.ID, &c.Name, pq.Array(&productIDs)); err != nil {
+			return nil, dbError(ctx, err)
+		}
+		c.ProductIDs = []int{}
+		for _, id := range productIDs {
+			c.ProductIDs = append(c.ProductIDs, int(id))
+		}
+		categories = append(categories, c)
+	}
+	return categories, dbError(ctx, rows.Err())
+}
+
+func (r *PostgresCategoryRepository) Create(ctx context.Context, c *Category) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, "insert into categories (name) values ($1) returning id", c.Name).Scan(&c.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return ErrCategoryExists
+	} else if err != nil {
+		return dbError(ctx, err)
+	}
+	for _, productID := range c.ProductIDs {
+		if _, err := tx.ExecContext(ctx, "insert into product_categories (product_id, category_id) values ($1, $2) on conflict do nothing",
+			productID, c.ID); err != nil {
+			return dbError(ctx, err)
+		}
+	}
+	return dbError(ctx, tx.Commit())
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotCSVList = errors.New("only lists of structs with csv tags can be written as CSV")
+
+// Write a list of structs as CSV. The columns are the fields having a csv
+// tag, in the order they're declared, and the first line holds their names.
+// The text cells that a spreadsheet would run as a formula are prefixed
+// with a quote, which ReadCSV removes
+func WriteCSV(w io.Writer, list interface{}) error {
+	v := reflect.ValueOf(list)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return ErrNotCSVList
+	}
+
+	elemType := v.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return ErrNotCSVList
+	}
+	header, fields := csvColumns(elemType)
+	if len(fields) == 0 {
+		return ErrNotCSVList
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				return fmt.Errorf("the element %d of the list is nil", i)
+			}
+			elem = elem.Elem()
+		}
+		record := make([]string, len(fields))
+		for j, field := range fields {
+			record[j] = csvValue(elem.Field(field))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Read CSV written by WriteCSV into a pointer to a list of structs. The
+// first line names the columns, which can come in any order. The fields
+// without a column are left to their zero value
+func ReadCSV(r io.Reader, list interface{}) error {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice ||
+		v.Elem().Type().Elem().Kind() != reflect.Struct {
+		return ErrNotCSVList
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+
+	names, fields := csvColumns(elemType)
+	fieldOf := map[string]int{}
+	for i, name := range names {
+		fieldOf[name] = fields[i]
+	}
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := make([]int, len(header))
+	for i, name := range header {
+		field, ok := fieldOf[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown column %q", name)
+		}
+		columns[i] = field
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		elem := reflect.New(elemType).Elem()
+		for i, cell := range record {
+			if err := setCSVValue(elem.Field(columns[i]), cell); err != nil {
+				return fmt.Errorf("line %d, column %s: %w", line, header[i], err)
+			}
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+}
+
+// Return the column names and the indexes of the fields having a csv tag
+func csvColumns(t reflect.Type) ([]string, []int) {
+	header := []string{}
+	fields := []int{}
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("csv")
+		if name == "" || name == "-" {
+			continue
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+	return header, fields
+}
+
+// Format a field value as a CSV cell
+func csvValue(v reflect.Value) string {
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return escapeCSVFormula(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// The first characters making a spreadsheet run a cell as a formula. The
+// quote is escaped as well so that escaping can always be undone
+const csvFormulaChars = "=+-@\t\r'"
+
+// Prefix a text cell with a quote when it starts like a formula
+func escapeCSVFormula(cell string) string {
+	if cell != "" && strings.ContainsRune(csvFormulaChars, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// Remove the quote added by escapeCSVFormula
+func unescapeCSVFormula(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaChars, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// Parse a CSV cell into a field value, the reverse of csvValue
+func setCSVValue(v reflect.Value, cell string) error {
+	if cell == "" && v.Kind() != reflect.String {
+		return nil
+	}
+	if _, ok := v.Interface().(time.Time); ok {
+		t, err := time.Parse(time.RFC3339, cell)
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(unescapeCSVFormula(cell))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("can't read a %s from CSV", v.Type())
+	}
+	return nil
+}
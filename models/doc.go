@@ -0,0 +1,7 @@
+// Package models holds the data of the store and the repositories keeping
+// it. Every repository is an interface with two implementations. The
+// Postgres one is used when serving requests. It works on the connection
+// pool shared by all the repositories, which it never closes, and fails with
+// ErrUnavailable when the database can't serve a request. The in-memory one
+// is for tests and development, its data is lost on restart
+package models
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// A product bought in an order. Its name and price are copied from the
+// catalog when the order is placed, so later changes don't affect the order
+type OrderItem struct {
+	ProductID int     `json:"product_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Quantity  int     `json:"quantity"`
+}
+
+type Order struct {
+	ID        int         `json:"id" csv:"id"`
+	Username  string      `json:"username" csv:"username"`
+	Status    string      `json:"status" csv:"status"`
+	Total     float64     `json:"total" csv:"total"`
+	CreatedAt time.Time   `json:"created_at" csv:"created_at"`
+	Items     []OrderItem `json:"items"`
+	// The payment authorization at the provider, once the order is paid
+	PaymentID string `json:"payment_id,omitempty" csv:"payment_id"`
+	// The status changes of the order, oldest first. Only filled in when
+	// fetching a single order
+	History []OrderStatusChange `json:"history,omitempty"`
+}
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrEmptyCart     = errors.New("the cart is empty")
+)
+
+// The storage backing the orders
+type OrderRepository interface {
+	// Create an order for the user out of the given cart entries and take
+	// the bought quantities out of stock. Either the whole order is placed
+	// or nothing changes, failing with ErrInsufficientStock when a product
+	// doesn't have enough units left
+	Place(ctx context.Context, username string, entries []CartEntry) (*Order, error)
+	// Fetch a single order by its ID
+	ByID(ctx context.Context, id int) (*Order, error)
+	// Return the orders of a user, most recent first
+	ByUsername(ctx context.Context, username string) ([]Order, error)
+	// Return all the orders, most recent first
+	All(ctx context.Context) ([]Order, error)
+	// Move an order to another status, recording who did it. Fails with
+	// ErrInvalidTransition when the order can't go from its current status
+	// to the new one. Cancelled orders put their products back in stock
+	UpdateStatus(ctx context.Context, id int, status, changedBy string) (*Order, error)
+	// Move an order to another status like UpdateStatus, calling before
+	// once the order is locked and the transition checked. The status is
+	// only changed when before succeeds, and no other change of the order
+	// can happen in between
+	UpdateStatusAfter(ctx context.Context, id int, status, changedBy string, before func(*Order) error) (*Order, error)
+	// Remember the payment authorization of an order
+	AttachPayment(ctx context.Context, id int, paymentID string) error
+}
+
+// Turn the owner's cart into an order for the user and pay for it. The cart
+// is emptied first, so a checkout submitted twice finds it empty the second
+// time and fails with ErrEmptyCart. If the order can't be placed or paid,
+// the order is cancelled and the products are put back in the cart
+func Checkout(ctx context.Context, carts CartRepository, orders OrderRepository, payments PaymentGateway, owner, username string) (*Order, error) {
+	entries, err := carts.Take(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	// The cart is taken from now on. Placing and paying the order, or
+	// putting the cart back when they fail, must complete even if the
+	// client goes away, so they no longer follow the request. Each query
+	// keeps its QueryTimeout
+	ctx = detach(ctx)
+	order, err := orders.Place(ctx, username, entries)
+	if err == nil {
+		order, err = PayOrder(ctx, orders, payments, order)
+	}
+	if err != nil {
+		if restoreErr := restoreCart(ctx, carts, owner, entries); restoreErr != nil {
+			return nil, restoreErr
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// Put the entries taken by a checkout back in the owner's cart, adding them
+// to the products put in it since
+func restoreCart(ctx context.Context, carts CartRepository, owner string, entries []CartEntry) error {
+	current, err := carts.Entries(ctx, owner)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		quantity := e.Quantity
+		for _, c := range current {
+			if c.ProductID == e.ProductID {
+				quantity += c.Quantity
+			}
+		}
+		if err := carts.SetQuantity(ctx, owner, e.ProductID, quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A context keeping the values of its parent but none of its cancellation
+// or deadline
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// Return a context which isn't cancelled along with the given one, for the
+// work that must complete once started
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// Move an order to another status on behalf of a user. Refunded orders get
+// their payment given back through the gateway first, while the order is
+// locked so that two refunds can't both reach the gateway. Refunds can be
+// retried, so an order whose status couldn't be saved after its refund can
+// still be moved to refunded
+func ChangeOrderStatus(ctx context.Context, orders OrderRepository, payments PaymentGateway, id int, status, changedBy string) (*Order, error) {
+	if status != OrderRefunded {
+		return orders.UpdateStatus(ctx, id, status, changedBy)
+	}
+	return orders.UpdateStatusAfter(ctx, id, status, changedBy, func(order *Order) error {
+		if order.PaymentID == "" {
+			return nil
+		}
+		return payments.Refund(ctx, order.PaymentID, order.Total)
+	})
+}
+
+// Sum up the price of the items
+func orderTotal(items []OrderItem) float64 {
+	total := 0.0
+	for _, item := range items {
+		total += item.Price * float64(item.Quantity)
+	}
+	return total
+}
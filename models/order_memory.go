@@ -0,0 +1,171 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Order repository keeping the orders in memory. The stock is taken from the
+// given in-memory product repository
+type MemoryOrderRepository struct {
+	mu       sync.RWMutex
+	products *MemoryProductRepository
+	orders   []Order
+	history  map[int][]OrderStatusChange
+	nextID   int
+}
+
+func NewMemoryOrderRepository(products *MemoryProductRepository) *MemoryOrderRepository {
+	return &MemoryOrderRepository{products: products, history: map[int][]OrderStatusChange{}, nextID: 1}
+}
+
+func (r *MemoryOrderRepository) Place(ctx context.Context, username string, entries []CartEntry) (*Order, error) {
+	if len(entries) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	// Hold the products lock while checking and taking the stock, so that
+	// two orders can't both get the last units
+	r.products.mu.Lock()
+	defer r.products.mu.Unlock()
+
+	// A product listed more than once needs stock for all of its entries
+	wanted := map[int]int{}
+	for _, e := range entries {
+		wanted[e.ProductID] += e.Quantity
+	}
+
+	items := []OrderItem{}
+	for _, e := range entries {
+		if e.Quantity <= 0 {
+			return nil, ErrInvalidQuantity
+		}
+		i := r.products.indexOf(e.ProductID)
+		if i < 0 {
+			return nil, ErrProductNotFound
+		}
+		p := r.products.products[i]
+		if p.Quantity < wanted[p.Id] {
+			return nil, ErrInsufficientStock
+		}
+		items = append(items, OrderItem{ProductID: p.Id, Name: p.Name, Price: p.Price, Quantity: e.Quantity})
+	}
+	for _, item := range items {
+		r.products.products[r.products.indexOf(item.ProductID)].Quantity -= item.Quantity
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	order := Order{
+		ID:        r.nextID,
+		Username:  username,
+		Status:    OrderPending,
+		Total:     orderTotal(items),
+		CreatedAt: time.Now(),
+		Items:     items,
+	}
+	r.nextID++
+	r.orders = append(r.orders, order)
+	r.history[order.ID] = []OrderStatusChange{{To: order.Status, ChangedBy: username, ChangedAt: order.CreatedAt}}
+	return copyOrder(order), nil
+}
+
+func (r *MemoryOrderRepository) ByID(ctx context.Context, id int) (*Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return nil, ErrOrderNotFound
+	}
+	o := copyOrder(r.orders[i])
+	o.History = append([]OrderStatusChange{}, r.history[id]...)
+	return o, nil
+}
+
+func (r *MemoryOrderRepository) All(ctx context.Context) ([]Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	orders := []Order{}
+	for i := len(r.orders) - 1; i >= 0; i-- {
+		orders = append(orders, *copyOrder(r.orders[i]))
+	}
+	return orders, nil
+}
+
+func (r *MemoryOrderRepository) UpdateStatus(ctx context.Context, id int, status, changedBy string) (*Order, error) {
+	return r.UpdateStatusAfter(ctx, id, status, changedBy, nil)
+}
+
+func (r *MemoryOrderRepository) UpdateStatusAfter(ctx context.Context, id int, status, changedBy string, before func(*Order) error) (*Order, error) {
+	// Take the locks in the same order as Place does
+	r.products.mu.Lock()
+	defer r.products.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return nil, ErrOrderNotFound
+	}
+	from := r.orders[i].Status
+	if !CanTransition(from, status) {
+		return nil, ErrInvalidTransition
+	}
+	if before != nil {
+		if err := before(copyOrder(r.orders[i])); err != nil {
+			return nil, err
+		}
+	}
+	r.orders[i].Status = status
+	if status == OrderCancelled {
+		for _, item := range r.orders[i].Items {
+			if j := r.products.indexOf(item.ProductID); j >= 0 {
+				r.products.products[j].Quantity += item.Quantity
+			}
+		}
+	}
+	r.history[id] = append(r.history[id], OrderStatusChange{From: from, To: status, ChangedBy: changedBy, ChangedAt: time.Now()})
+
+	o := copyOrder(r.orders[i])
+	o.History = append([]OrderStatusChange{}, r.history[id]...)
+	return o, nil
+}
+
+func (r *MemoryOrderRepository) AttachPayment(ctx context.Context, id int, paymentID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return ErrOrderNotFound
+	}
+	r.orders[i].PaymentID = paymentID
+	return nil
+}
+
+// Position of the order in the list or -1. Callers must hold the lock
+func (r *MemoryOrderRepository) indexOf(id int) int {
+	for i, o := range r.orders {
+		if o.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *MemoryOrderRepository) ByUsername(ctx context.Context, username string) ([]Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	orders := []Order{}
+	for i := len(r.orders) - 1; i >= 0; i-- {
+		if r.orders[i].Username == username {
+			orders = append(orders, *copyOrder(r.orders[i]))
+		}
+	}
+	return orders, nil
+}
+
+// Copy an order so that callers can't modify the stored items
+func copyOrder(o Order) *Order {
+	o.Items = append([]OrderItem{}, o.Items...)
+	return &o
+}
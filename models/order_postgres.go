@@ -0,0 +1,257 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// Order repository backed by the orders and order_items tables
+type PostgresOrderRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresOrderRepository(db *sql.DB) *PostgresOrderRepository {
+	return &PostgresOrderRepository{db: db}
+}
+
+func (r *PostgresOrderRepository) Place(ctx context.Context, username string, entries []CartEntry) (*Order, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if len(entries) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	// Lock the product rows always in the same order so that two concurrent
+	// checkouts can't deadlock
+	entries = append([]CartEntry{}, entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ProductID < entries[j].ProductID })
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	items := []OrderItem{}
+	for _, e := range entries {
+		if e.Quantity <= 0 {
+			return nil, ErrInvalidQuantity
+		}
+		item := OrderItem{ProductID: e.ProductID, Quantity: e.Quantity}
+		var stock int
+		err := tx.QueryRowContext(ctx, "select name, price, quantity from products where id = $1 for update", e.ProductID).
+			Scan(&item.Name, &item.Price, &stock)
+		if err == sql.ErrNoRows {
+			return nil, ErrProductNotFound
+		} else if err != nil {
+			return nil, dbError(ctx, err)
+		}
+		if stock < e.Quantity {
+			return nil, ErrInsufficientStock
+		}
+		if _, err := tx.ExecContext(ctx, "update products set quantity = quantity - $1 where id = $2", e.Quantity, e.ProductID); err != nil {
+			return nil, dbError(ctx, err)
+		}
+		items = append(items, item)
+	}
+
+	order := Order{Username: username, Status: OrderPending, Total: orderTotal(items), Items: items}
+	err = tx.QueryRowContext(ctx, "insert into orders (username, status, total) values ($1, $2, $3) returning id, created_at",
+		order.Username, order.Status, order.Total).Scan(&order.ID, &order.CreatedAt)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx, "insert into order_items (order_id, product_id, name, price, quantity) values ($1, $2, $3, $4, $5)",
+			order.ID, item.ProductID, item.Name, item.Price, item.Quantity)
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, "insert into order_status_history (order_id, to_status, changed_by, changed_at) values ($1, $2, $3, $4)",
+		order.ID, order.Status, username, order.CreatedAt)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &order, nil
+}
+
+func (r *PostgresOrderRepository) ByID(ctx context.Context, id int) (*Order, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	o := Order{}
+	err := r.db.QueryRowContext(ctx, "select id, username, status, total, created_at, coalesce(payment_id, '') from orders where id = $1", id).
+		Scan(&o.ID, &o.Username, &o.Status, &o.Total, &o.CreatedAt, &o.PaymentID)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	} else if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	orders := []Order{o}
+	if err := r.fillItems(ctx, orders); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	o = orders[0]
+	if o.History, err = r.history(ctx, o.ID); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &o, nil
+}
+
+func (r *PostgresOrderRepository) ByUsername(ctx context.Context, username string) ([]Order, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return r.list(ctx, "select id, username, status, total, created_at, coalesce(payment_id, '') from orders where username = $1 order by id desc", username)
+}
+
+func (r *PostgresOrderRepository) All(ctx context.Context) ([]Order, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return r.list(ctx, "select id, username, status, total, created_at, coalesce(payment_id, '') from orders order by id desc")
+}
+
+func (r *PostgresOrderRepository) UpdateStatus(ctx context.Context, id int, status, changedBy string) (*Order, error) {
+	return r.UpdateStatusAfter(ctx, id, status, changedBy, nil)
+}
+
+func (r *PostgresOrderRepository) UpdateStatusAfter(ctx context.Context, id int, status, changedBy string, before func(*Order) error) (*Order, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	// Lock the order so that two changes can't both start from the same status
+	o := Order{ID: id}
+	err = tx.QueryRowContext(ctx, "select username, status, total, created_at, coalesce(payment_id, '') from orders where id = $1 for update", id).
+		Scan(&o.Username, &o.Status, &o.Total, &o.CreatedAt, &o.PaymentID)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	} else if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	from := o.Status
+	if !CanTransition(from, status) {
+		return nil, ErrInvalidTransition
+	}
+	if before != nil {
+		if err := before(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "update orders set status = $1 where id = $2", status, id); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	if status == OrderCancelled {
+		_, err := tx.ExecContext(ctx, `update products p set quantity = p.quantity + i.quantity
+			from order_items i where i.order_id = $1 and i.product_id = p.id`, id)
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, "insert into order_status_history (order_id, from_status, to_status, changed_by) values ($1, $2, $3, $4)",
+		id, from, status, changedBy)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return r.ByID(ctx, id)
+}
+
+func (r *PostgresOrderRepository) AttachPayment(ctx context.Context, id int, paymentID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := r.db.ExecContext(ctx, "update orders set payment_id = $1 where id = $2", paymentID, id)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	return requireAffected(res, ErrOrderNotFound)
+}
+
+// Run a query selecting orders and fill in their items
+func (r *PostgresOrderRepository) list(ctx context.Context, query string, args ...interface{}) ([]Order, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+	orders := []Order{}
+	for rows.Next() {
+		o := Order{}
+		if err := rows.Scan(&o.ID, &o.Username, &o.Status, &o.Total, &o.CreatedAt, &o.PaymentID); err != nil {
+			return nil, dbError(ctx, err)
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+
+	if err := r.fillItems(ctx, orders); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return orders, nil
+}
+
+// Fill in the items of the orders, with a single query whatever their number
+func (r *PostgresOrderRepository) fillItems(ctx context.Context, orders []Order) error {
+	ids := make([]int64, len(orders))
+	byID := map[int]*Order{}
+	for i := range orders {
+		orders[i].Items = []OrderItem{}
+		ids[i] = int64(orders[i].ID)
+		byID[orders[i].ID] = &orders[i]
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `select order_id, coalesce(product_id, 0), name, price, quantity
+		from order_items where order_id = any($1) order by id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orderID int
+		item := OrderItem{}
+		if err := rows.Scan(&orderID, &item.ProductID, &item.Name, &item.Price, &item.Quantity); err != nil {
+			return err
+		}
+		if o, ok := byID[orderID]; ok {
+			o.Items = append(o.Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+// Return the status changes of an order, oldest first
+func (r *PostgresOrderRepository) history(ctx context.Context, orderID int) ([]OrderStatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `select coalesce(from_status, ''), to_status, changed_by, changed_at
+		from order_status_history where order_id = $1 order by id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := []OrderStatusChange{}
+	for rows.Next() {
+		change := OrderStatusChange{}
+		if err := rows.Scan(&change.From, &change.To, &change.ChangedBy, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// The statuses an order goes through
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+var ErrInvalidTransition = errors.New("the order can't be moved to this status")
+
+// The statuses an order can be moved to from each status. Cancelled and
+// refunded orders are final
+var orderTransitions = map[string][]string{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderShipped, OrderRefunded},
+	OrderShipped:   {OrderDelivered},
+	OrderDelivered: {OrderRefunded},
+}
+
+// A change of the status of an order. From is empty for the change made
+// when the order was placed
+type OrderStatusChange struct {
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Return the statuses an order can be moved to from the given status
+func NextOrderStatuses(status string) []string {
+	return append([]string{}, orderTransitions[status]...)
+}
+
+// Check whether an order can be moved from a status to another one
+func CanTransition(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A payment approved by the provider for an amount, to be captured later
+type PaymentAuthorization struct {
+	ID        string  `json:"id"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+// The kinds of event a payment provider notifies through its webhook
+const (
+	PaymentCaptured = "payment.captured"
+	PaymentRefunded = "payment.refunded"
+	PaymentFailed   = "payment.failed"
+)
+
+// A notification sent by the payment provider
+type PaymentEvent struct {
+	Type            string  `json:"type"`
+	AuthorizationID string  `json:"authorization_id"`
+	Reference       string  `json:"reference"`
+	Amount          float64 `json:"amount"`
+}
+
+var (
+	ErrPaymentDeclined  = errors.New("the payment was declined")
+	ErrPaymentTimeout   = errors.New("the payment provider didn't answer in time")
+	ErrPaymentNotFound  = errors.New("payment not found")
+	ErrInvalidSignature = errors.New("the webhook signature is invalid")
+	ErrWebhooksDisabled = errors.New("the webhooks are disabled as no secret is configured")
+)
+
+// The provider charging the customers. The fake implementation is used for
+// development and tests, a real provider only needs to implement it as well
+type PaymentGateway interface {
+	// Reserve an amount on the customer's payment method. The reference
+	// identifies what is paid for
+	Authorize(ctx context.Context, amount float64, reference string) (*PaymentAuthorization, error)
+	// Charge the amount reserved by an authorization
+	Capture(ctx context.Context, authorizationID string) error
+	// Give back an amount of a captured payment, or release an
+	// authorization that wasn't captured. Giving back a payment that was
+	// fully given back already succeeds, so that a refund can be retried
+	Refund(ctx context.Context, authorizationID string, amount float64) error
+	// Check that a webhook payload was sent by the provider and decode it.
+	// Fails with ErrWebhooksDisabled when there's no secret to check it with
+	VerifyWebhook(payload []byte, signature string) (*PaymentEvent, error)
+}
+
+// The reference sent to the payment provider for an order
+func OrderPaymentReference(orderID int) string {
+	return "order-" + strconv.Itoa(orderID)
+}
+
+// The ID of the order a payment reference was made for
+func OrderIDFromPaymentReference(reference string) (int, error) {
+	if !strings.HasPrefix(reference, "order-") {
+		return 0, ErrOrderNotFound
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(reference, "order-"))
+	if err != nil {
+		return 0, ErrOrderNotFound
+	}
+	return id, nil
+}
+
+// Charge the total of a pending order and mark it as paid. When the payment
+// doesn't go through the order is cancelled, which puts its products back
+// in stock, and the payment error is returned. A payment that can't be
+// recorded on the order is refunded before the order is cancelled
+func PayOrder(ctx context.Context, orders OrderRepository, payments PaymentGateway, order *Order) (*Order, error) {
+	auth, err := payments.Authorize(ctx, order.Total, OrderPaymentReference(order.ID))
+	if err != nil {
+		return nil, cancelUnpaidOrder(ctx, orders, order, err)
+	}
+	if err := orders.AttachPayment(ctx, order.ID, auth.ID); err != nil {
+		return nil, refundUnpaidOrder(ctx, orders, payments, order, auth, err)
+	}
+	if err := payments.Capture(ctx, auth.ID); err != nil {
+		return nil, refundUnpaidOrder(ctx, orders, payments, order, auth, err)
+	}
+
+	// The customer is charged from now on, so the order must not stay
+	// pending: its cart would be kept and could be paid a second time
+	paid, err := markOrderPaid(ctx, orders, order)
+	if err != nil {
+		return nil, refundUnpaidOrder(ctx, orders, payments, order, auth, err)
+	}
+	return paid, nil
+}
+
+// The name recorded in the order history for the changes made by payments
+const PaymentSystemUser = "payments"
+
+// How many times a captured payment is recorded on its order before giving
+// up, and how long to wait after the first failure. The wait doubles every
+// time
+const (
+	markPaidAttempts = 3
+	markPaidBackoff  = 100 * time.Millisecond
+)
+
+// Move an order whose payment was captured to paid, retrying when the
+// repository fails
+func markOrderPaid(ctx context.Context, orders OrderRepository, order *Order) (*Order, error) {
+	backoff := markPaidBackoff
+	for attempt := 1; ; attempt++ {
+		paid, err := orders.UpdateStatus(ctx, order.ID, OrderPaid, PaymentSystemUser)
+		if err == nil || err == ErrOrderNotFound || err == ErrInvalidTransition || attempt == markPaidAttempts {
+			return paid, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// Give the payment of an order back then cancel the order, and return the
+// error that stopped the payment. The order is left as it is when the
+// refund fails, so that the payment can still be found from it
+func refundUnpaidOrder(ctx context.Context, orders OrderRepository, payments PaymentGateway,
+	order *Order, auth *PaymentAuthorization, paymentErr error) error {
+	if err := payments.Refund(ctx, auth.ID, auth.Amount); err != nil {
+		return &RefundError{AuthorizationID: auth.ID, OrderID: order.ID, Cause: paymentErr, Err: err}
+	}
+	return cancelUnpaidOrder(ctx, orders, order, paymentErr)
+}
+
+// Cancel an order whose payment failed and return the payment error
+func cancelUnpaidOrder(ctx context.Context, orders OrderRepository, order *Order, paymentErr error) error {
+	if _, err := orders.UpdateStatus(ctx, order.ID, OrderCancelled, PaymentSystemUser); err != nil {
+		return err
+	}
+	return paymentErr
+}
+
+// Returned when a payment had to be given back, because it couldn't be
+// completed, but the refund failed too. The customer may have been charged
+// for an order that isn't paid, which needs to be sorted out by hand
+type RefundError struct {
+	AuthorizationID string
+	OrderID         int
+	// What stopped the payment
+	Cause error
+	// What stopped the refund
+	Err error
+}
+
+func (e *RefundError) Error() string {
+	return fmt.Sprintf("the payment %s of the order %d failed (%v) and couldn't be refunded: %v",
+		e.AuthorizationID, e.OrderID, e.Cause, e.Err)
+}
+
+func (e *RefundError) Unwrap() error {
+	return e.Err
+}
+
+// Apply a verified webhook event to the order it was sent for
+func HandlePaymentEvent(ctx context.Context, orders OrderRepository, event *PaymentEvent) (*Order, error) {
+	id, err := OrderIDFromPaymentReference(event.Reference)
+	if err != nil {
+		return nil, err
+	}
+	order, err := orders.ByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var status string
+	switch event.Type {
+	case PaymentCaptured:
+		status = OrderPaid
+	case PaymentRefunded:
+		status = OrderRefunded
+	case PaymentFailed:
+		status = OrderCancelled
+	default:
+		// Events we don't care about are acknowledged and ignored
+		return order, nil
+	}
+	if order.Status == status {
+		// Providers may send the same event more than once
+		return order, nil
+	}
+	return orders.UpdateStatus(ctx, id, status, PaymentSystemUser)
+}
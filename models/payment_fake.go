@@ -0,0 +1,169 @@
+package models
+
+import (
+	"GolangStore/config"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The behaviours of the fake payment gateway, the modes the configuration
+// accepts
+const (
+	FakePaymentSucceed = config.FakePaymentSucceed
+	FakePaymentDecline = config.FakePaymentDecline
+	FakePaymentTimeout = config.FakePaymentTimeout
+)
+
+// Payment gateway that doesn't charge anything. Depending on its mode every
+// authorization succeeds, is declined or times out, and the IDs it hands
+// out are sequential so that runs are reproducible
+type FakePaymentGateway struct {
+	// How long the calls wait in timeout mode before failing, unless their
+	// context is done first
+	TimeoutDelay time.Duration
+
+	mu             sync.Mutex
+	mode           string
+	secret         []byte
+	authorizations map[string]*fakePayment
+	nextID         int
+}
+
+type fakePayment struct {
+	authorization PaymentAuthorization
+	captured      bool
+	refunded      float64
+}
+
+// Create a fake gateway in the given mode. Webhooks are signed with the
+// secret, and refused when it's empty. In timeout mode the calls wait 10
+// seconds
+func NewFakePaymentGateway(mode, webhookSecret string) *FakePaymentGateway {
+	return &FakePaymentGateway{
+		TimeoutDelay:   10 * time.Second,
+		mode:           mode,
+		secret:         []byte(webhookSecret),
+		authorizations: map[string]*fakePayment{},
+		nextID:         1,
+	}
+}
+
+// Change the behaviour of the gateway for the next calls
+func (g *FakePaymentGateway) SetMode(mode string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.mode = mode
+}
+
+func (g *FakePaymentGateway) Authorize(ctx context.Context, amount float64, reference string) (*PaymentAuthorization, error) {
+	if g.timesOut(ctx) {
+		return nil, ErrPaymentTimeout
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.mode == FakePaymentDecline {
+		return nil, ErrPaymentDeclined
+	}
+
+	auth := PaymentAuthorization{ID: "fake_auth_" + strconv.Itoa(g.nextID), Amount: amount, Reference: reference}
+	g.nextID++
+	g.authorizations[auth.ID] = &fakePayment{authorization: auth}
+	return &auth, nil
+}
+
+func (g *FakePaymentGateway) Capture(ctx context.Context, authorizationID string) error {
+	if g.timesOut(ctx) {
+		return ErrPaymentTimeout
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	payment, ok := g.authorizations[authorizationID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	payment.captured = true
+	return nil
+}
+
+func (g *FakePaymentGateway) Refund(ctx context.Context, authorizationID string, amount float64) error {
+	if g.timesOut(ctx) {
+		return ErrPaymentTimeout
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	payment, ok := g.authorizations[authorizationID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if payment.refunded == payment.authorization.Amount {
+		return nil
+	}
+	if payment.refunded+amount > payment.authorization.Amount {
+		return ErrPaymentDeclined
+	}
+	payment.refunded += amount
+	return nil
+}
+
+// In timeout mode, wait like a provider that doesn't answer until the delay
+// has passed or the context is done, and report that the call timed out
+func (g *FakePaymentGateway) timesOut(ctx context.Context) bool {
+	g.mu.Lock()
+	timeout, delay := g.mode == FakePaymentTimeout, g.TimeoutDelay
+	g.mu.Unlock()
+	if !timeout {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return true
+}
+
+// Whether an authorization was captured, and the amount refunded from it
+func (g *FakePaymentGateway) Payment(authorizationID string) (captured bool, refunded float64, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	payment, ok := g.authorizations[authorizationID]
+	if !ok {
+		return false, 0, ErrPaymentNotFound
+	}
+	return payment.captured, payment.refunded, nil
+}
+
+func (g *FakePaymentGateway) VerifyWebhook(payload []byte, signature string) (*PaymentEvent, error) {
+	// Anyone can sign a payload with an empty key
+	if len(g.secret) == 0 {
+		return nil, ErrWebhooksDisabled
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, g.sign(payload)) {
+		return nil, ErrInvalidSignature
+	}
+	event := PaymentEvent{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// Return the signature the gateway expects for a webhook payload, to
+// simulate a notification from the provider
+func (g *FakePaymentGateway) SignWebhook(payload []byte) string {
+	return hex.EncodeToString(g.sign(payload))
+}
+
+func (g *FakePaymentGateway) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
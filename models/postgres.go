@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// How long a single query of the Postgres repositories may take. Zero means
+// no limit besides the one of the request. It's set from the configuration
+// before serving
+var QueryTimeout = 5 * time.Second
+
+// Returned by the Postgres repositories when the database can't serve the
+// request right now, because it can't be reached or is overloaded. The same
+// request may succeed later
+var ErrUnavailable = errors.New("the database is unavailable")
+
+// Postgres error code raised when a statement is cancelled, as when it runs
+// longer than the statement_timeout of the server
+const queryCanceled = "57014"
+
+// Returned by the Postgres repositories when a query is stopped before
+// completing, because the request was cancelled, by the client going away,
+// or because it took longer than QueryTimeout
+type CanceledError struct {
+	// context.Canceled or context.DeadlineExceeded
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	if e.Timeout() {
+		return "the query took too long"
+	}
+	return "the query was cancelled"
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// Whether the query was stopped by the timeout rather than by the client
+func (e *CanceledError) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Derive the context of a query from the one of the request, limiting it
+// to QueryTimeout
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, QueryTimeout)
+}
+
+// The classes of the Postgres error codes meaning that the database can't
+// serve the request: connection exception, insufficient resources and
+// operator intervention
+var unavailableClasses = map[pq.ErrorClass]bool{"08": true, "53": true, "57": true}
+
+// An ErrUnavailable keeping the error of the driver
+type unavailableError struct {
+	cause error
+}
+
+func (e *unavailableError) Error() string {
+	return ErrUnavailable.Error() + ": " + e.cause.Error()
+}
+
+func (e *unavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.cause
+}
+
+// Turn the errors of the driver into a CanceledError when the context of the
+// query is done, or into ErrUnavailable when the database can't serve the
+// request. The other errors are returned as they are
+func dbError(ctx context.Context, err error) error {
+	var canceled *CanceledError
+	if err == nil || errors.Is(err, ErrUnavailable) || errors.As(err, &canceled) {
+		return err
+	}
+	// Whatever the driver reports once the context is done is caused by it
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return &CanceledError{Err: ctxErr}
+	}
+	var pqErr *pq.Error
+	isPQErr := errors.As(err, &pqErr)
+	if isPQErr && pqErr.Code == queryCanceled {
+		return &CanceledError{Err: context.DeadlineExceeded}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.As(err, &netErr):
+	case isPQErr && unavailableClasses[pqErr.Code.Class()]:
+	default:
+		return err
+	}
+	return &unavailableError{cause: err}
+}
+
+// The queries of a repository, run by *sql.DB as well as by *sql.Tx
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Turn a statement that didn't touch any row into the given not found error
+func requireAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
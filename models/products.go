@@ -1,30 +1,48 @@
 package models
 
-import "GolangStore/database"
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+)
 
 type Product struct {
-	Id          int
-	Name        string
-	Description string
-	Price       float64
-	Quantity    int
+	Id          int     `json:"id" csv:"id"`
+	Name        string  `json:"name" csv:"name"`
+	Description string  `json:"description" csv:"description"`
+	Price       float64 `json:"price" csv:"price"`
+	Quantity    int     `json:"quantity" csv:"quantity"`
 }
 
-func ProductFinder() []Product {
-	db := database.Connect()
-	defer db.Close()
-	selectAll, err := db.Query("select * from products")
-	if err != nil {
-		panic(err.Error())
-	}
-	p := Product{}
-	products := []Product{}
-	for selectAll.Next() {
-		err = selectAll.Scan(&p.Id, &p.Name, &p.Description, &p.Price, &p.Quantity)
-		if err != nil {
-			panic(err.Error())
-		}
-		products = append(products, p)
+var ErrProductNotFound = errors.New("product not found")
+
+// The storage backing the product catalog
+type ProductRepository interface {
+	// Return a list of all the products
+	All(ctx context.Context) ([]Product, error)
+	// Fetch a single product by its ID
+	ByID(ctx context.Context, id int) (*Product, error)
+	// Store a new product, setting its ID
+	Create(ctx context.Context, p *Product) error
+	// Overwrite the stored product having the same ID
+	Update(ctx context.Context, p *Product) error
+	// Remove the product with the given ID
+	Delete(ctx context.Context, id int) error
+}
+
+// Check that the product fields hold acceptable values
+func (p *Product) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("the product name can't be empty")
+	} else if math.IsNaN(p.Price) || math.IsInf(p.Price, 0) {
+		// They can't be stored as numeric nor encoded to JSON
+		return errors.New("the price must be a number")
+	} else if p.Price < 0 {
+		return errors.New("the price can't be negative")
+	} else if p.Quantity < 0 {
+		return errors.New("the quantity can't be negative")
 	}
-	return products
+	return nil
 }
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"context"
+	"sync"
+)
+
+// Product repository keeping the catalog in memory
+type MemoryProductRepository struct {
+	mu       sync.RWMutex
+	products []Product
+	nextID   int
+}
+
+// Create a repository holding a copy of the given products
+func NewMemoryProductRepository(products ...Product) *MemoryProductRepository {
+	r := &MemoryProductRepository{nextID: 1}
+	for _, p := range products {
+		r.products = append(r.products, p)
+		if p.Id >= r.nextID {
+			r.nextID = p.Id + 1
+		}
+	}
+	return r
+}
+
+func (r *MemoryProductRepository) All(ctx context.Context) ([]Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	products := make([]Product, len(r.products))
+	copy(products, r.products)
+	return products, nil
+}
+
+func (r *MemoryProductRepository) ByID(ctx context.Context, id int) (*Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if i := r.indexOf(id); i >= 0 {
+		p := r.products[i]
+		return &p, nil
+	}
+	return nil, ErrProductNotFound
+}
+
+func (r *MemoryProductRepository) Create(ctx context.Context, p *Product) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.Id = r.nextID
+	r.nextID++
+	r.products = append(r.products, *p)
+	return nil
+}
+
+func (r *MemoryProductRepository) Update(ctx context.Context, p *Product) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexOf(p.Id)
+	if i < 0 {
+		return ErrProductNotFound
+	}
+	r.products[i] = *p
+	return nil
+}
+
+func (r *MemoryProductRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return ErrProductNotFound
+	}
+	r.products = append(r.products[:i], r.products[i+1:]...)
+	return nil
+}
+
+// Position of the product in the list or -1. Callers must hold the lock
+func (r *MemoryProductRepository) indexOf(id int) int {
+	for i, p := range r.products {
+		if p.Id == id {
+			return i
+		}
+	}
+	return -1
+}
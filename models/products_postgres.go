@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Product repository backed by the products table
+type PostgresProductRepository struct {
+	db Queryer
+}
+
+// Given a transaction, the repository works inside it
+func NewPostgresProductRepository(db Queryer) *PostgresProductRepository {
+	return &PostgresProductRepository{db: db}
+}
+
+// The columns scanned by scanProduct, in order
+const productColumns = "id, name, description, price, quantity"
+
+func (r *PostgresProductRepository) All(ctx context.Context) ([]Product, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, "select "+productColumns+" from products order by id")
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	products := []Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+		products = append(products, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return products, nil
+}
+
+func (r *PostgresProductRepository) ByID(ctx context.Context, id int) (*Product, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	p, err := scanProduct(r.db.QueryRowContext(ctx, "select "+productColumns+" from products where id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	return p, dbError(ctx, err)
+}
+
+func (r *PostgresProductRepository) Create(ctx context.Context, p *Product) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	err := r.db.QueryRowContext(ctx, `insert into products (name, description, price, quantity)
+		values ($1, $2, $3, $4) returning id`, p.Name, p.Description, p.Price, p.Quantity).Scan(&p.Id)
+	return dbError(ctx, err)
+}
+
+func (r *PostgresProductRepository) Update(ctx context.Context, p *Product) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, "update products set name = $1, description = $2, price = $3, quantity = $4 where id = $5",
+		p.Name, p.Description, p.Price, p.Quantity, p.Id)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	return requireAffected(res, ErrProductNotFound)
+}
+
+func (r *PostgresProductRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := r.db.ExecContext(ctx, "delete from products where id = $1", id)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	return requireAffected(res, ErrProductNotFound)
+}
+
+// Read a product out of a row holding productColumns
+func scanProduct(row interface {
+	Scan(dest ...interface{}) error
+}) (*Product, error) {
+	var p Product
+	if err := row.Scan(&p.Id, &p.Name, &p.Description, &p.Price, &p.Quantity); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// A logged in user, identified by the token stored in its cookie
+type Session struct {
+	Token     string    `json:"-"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// The storage keeping track of the sessions
+type SessionStore interface {
+	// Start a session for the user, valid for the given duration. The
+	// expired sessions are deleted along the way
+	Create(ctx context.Context, username string, ttl time.Duration) (*Session, error)
+	// Fetch the session of a token, failing with ErrSessionNotFound if it
+	// doesn't exist or has expired
+	Get(ctx context.Context, token string) (*Session, error)
+	// End the session of a token
+	Delete(ctx context.Context, token string) error
+}
+
+// Generate a random token that can't be guessed, to identify a session
+func NewSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Tokens are stored hashed so that a leaked table can't be used to log in
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
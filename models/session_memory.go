@@ -0,0 +1,60 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Session store keeping the sessions in memory
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]Session{}}
+}
+
+func (s *MemorySessionStore) Create(ctx context.Context, username string, ttl time.Duration) (*Session, error) {
+	token, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := Session{Token: token, Username: username, ExpiresAt: now.Add(ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop the expired sessions that were never read again, like the
+	// Postgres store does
+	for key, other := range s.sessions {
+		if !other.ExpiresAt.After(now) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[hashToken(token)] = session
+	return &session, nil
+}
+
+func (s *MemorySessionStore) Get(ctx context.Context, token string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := hashToken(token)
+	session, ok := s.sessions[key]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !session.ExpiresAt.After(time.Now()) {
+		delete(s.sessions, key)
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, hashToken(token))
+	return nil
+}
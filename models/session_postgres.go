@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session store backed by the sessions table
+type PostgresSessionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSessionStore(db *sql.DB) *PostgresSessionStore {
+	return &PostgresSessionStore{db: db}
+}
+
+func (s *PostgresSessionStore) Create(ctx context.Context, username string, ttl time.Duration) (*Session, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	token, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	session := Session{Token: token, Username: username, ExpiresAt: time.Now().Add(ttl)}
+
+	// The expired sessions are never read again, they're dropped whenever a
+	// session starts so that the table doesn't keep growing
+	_, err = s.db.ExecContext(ctx, `with expired as (delete from sessions where expires_at <= now())
+		insert into sessions (token_hash, username, expires_at) values ($1, $2, $3)`,
+		hashToken(token), session.Username, session.ExpiresAt)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &session, nil
+}
+
+func (s *PostgresSessionStore) Get(ctx context.Context, token string) (*Session, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	session := Session{Token: token}
+	err := s.db.QueryRowContext(ctx, "select username, expires_at from sessions where token_hash = $1 and expires_at > now()",
+		hashToken(token)).Scan(&session.Username, &session.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &session, nil
+}
+
+func (s *PostgresSessionStore) Delete(ctx context.Context, token string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, "delete from sessions where token_hash = $1", hashToken(token))
+	return dbError(ctx, err)
+}
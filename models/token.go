@@ -0,0 +1,200 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// A long lived token exchanged for new access tokens. Its ID stays the same
+// when it's rotated, and is carried by the access tokens so that revoking
+// the refresh token revokes them as well
+type RefreshToken struct {
+	ID        string    `json:"-"`
+	Token     string    `json:"-"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// The tokens handed to an API client
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrInvalidToken         = errors.New("the token is invalid")
+	ErrTokenExpired         = errors.New("the token has expired")
+	ErrTokenRevoked         = errors.New("the token has been revoked")
+)
+
+// The storage keeping track of the refresh tokens
+type RefreshTokenStore interface {
+	// Issue a refresh token for the user, valid for the given duration. The
+	// expired tokens are deleted along the way
+	Create(ctx context.Context, username string, ttl time.Duration) (*RefreshToken, error)
+	// Replace a refresh token with a new one having the same ID, failing
+	// with ErrRefreshTokenNotFound if it doesn't exist or has expired
+	Rotate(ctx context.Context, token string, ttl time.Duration) (*RefreshToken, error)
+	// Fetch a refresh token by ID, failing with ErrRefreshTokenNotFound if
+	// it was revoked or has expired
+	ByID(ctx context.Context, id string) (*RefreshToken, error)
+	// Revoke a refresh token
+	Delete(ctx context.Context, token string) error
+	// Revoke a refresh token by ID
+	DeleteByID(ctx context.Context, id string) error
+}
+
+// Issues the bearer tokens of the API and checks them. The access tokens
+// are JWTs signed with HMAC-SHA256, so they are checked without a lookup
+// apart from making sure their refresh token wasn't revoked
+type TokenIssuer struct {
+	secret     []byte
+	refresh    RefreshTokenStore
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// The claims of an access token
+type accessClaims struct {
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// The header of every access token
+var accessTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Create an issuer signing the access tokens with the secret. The access
+// tokens last 15 minutes and the refresh tokens 30 days
+func NewTokenIssuer(secret []byte, refresh RefreshTokenStore) *TokenIssuer {
+	return &TokenIssuer{
+		secret:     secret,
+		refresh:    refresh,
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 30 * 24 * time.Hour,
+	}
+}
+
+// Generate a random secret to sign the access tokens with. The tokens signed
+// with it can't be checked anymore once the application restarts
+func RandomTokenSecret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err.Error())
+	}
+	return b
+}
+
+// Issue a new pair of tokens for the user
+func (i *TokenIssuer) Issue(ctx context.Context, username string) (*TokenPair, error) {
+	refresh, err := i.refresh.Create(ctx, username, i.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return i.pair(refresh)
+}
+
+// Exchange a refresh token for a new pair of tokens. The refresh token
+// can't be used again afterwards
+func (i *TokenIssuer) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	refresh, err := i.refresh.Rotate(ctx, refreshToken, i.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return i.pair(refresh)
+}
+
+// Revoke a refresh token along with the access tokens issued for it
+func (i *TokenIssuer) Revoke(ctx context.Context, refreshToken string) error {
+	return i.refresh.Delete(ctx, refreshToken)
+}
+
+// Revoke the refresh token an access token was issued for, and so every
+// access token carrying it
+func (i *TokenIssuer) RevokeAccess(ctx context.Context, accessToken string) error {
+	claims, err := i.claims(accessToken)
+	if err != nil {
+		return err
+	}
+	return i.refresh.DeleteByID(ctx, claims.SessionID)
+}
+
+// Check an access token and return the username of its owner
+func (i *TokenIssuer) Verify(ctx context.Context, accessToken string) (string, error) {
+	claims, err := i.claims(accessToken)
+	if err != nil {
+		return "", err
+	}
+	if !time.Unix(claims.ExpiresAt, 0).After(time.Now()) {
+		return "", ErrTokenExpired
+	}
+
+	if _, err := i.refresh.ByID(ctx, claims.SessionID); err == ErrRefreshTokenNotFound {
+		return "", ErrTokenRevoked
+	} else if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+// Check the signature of an access token and decode its claims
+func (i *TokenIssuer) claims(accessToken string) (*accessClaims, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(accessTokenHeader)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, i.sign(parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims accessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// Sign an access token tied to the refresh token and pair them
+func (i *TokenIssuer) pair(refresh *RefreshToken) (*TokenPair, error) {
+	now := time.Now()
+	payload, err := json.Marshal(accessClaims{
+		Subject:   refresh.Username,
+		SessionID: refresh.ID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(i.AccessTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned := accessTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return &TokenPair{
+		AccessToken:  unsigned + "." + base64.RawURLEncoding.EncodeToString(i.sign(unsigned)),
+		TokenType:    "Bearer",
+		ExpiresIn:    int(i.AccessTTL.Seconds()),
+		RefreshToken: refresh.Token,
+	}, nil
+}
+
+func (i *TokenIssuer) sign(data string) []byte {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
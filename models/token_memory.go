@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Refresh token store keeping the tokens in memory
+type MemoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken
+}
+
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{tokens: map[string]RefreshToken{}}
+}
+
+func (s *MemoryRefreshTokenStore) Create(ctx context.Context, username string, ttl time.Duration) (*RefreshToken, error) {
+	id, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	token, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	refresh := RefreshToken{ID: id, Token: token, Username: username, ExpiresAt: now.Add(ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop the expired tokens like the Postgres store does
+	for key, other := range s.tokens {
+		if !other.ExpiresAt.After(now) {
+			delete(s.tokens, key)
+		}
+	}
+	s.tokens[hashToken(token)] = refresh
+	return &refresh, nil
+}
+
+func (s *MemoryRefreshTokenStore) Rotate(ctx context.Context, token string, ttl time.Duration) (*RefreshToken, error) {
+	newToken, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := hashToken(token)
+	refresh, ok := s.tokens[key]
+	if !ok || !refresh.ExpiresAt.After(time.Now()) {
+		delete(s.tokens, key)
+		return nil, ErrRefreshTokenNotFound
+	}
+	delete(s.tokens, key)
+	refresh.Token = newToken
+	refresh.ExpiresAt = time.Now().Add(ttl)
+	s.tokens[hashToken(newToken)] = refresh
+	return &refresh, nil
+}
+
+func (s *MemoryRefreshTokenStore) ByID(ctx context.Context, id string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, refresh := range s.tokens {
+		if refresh.ID == id && refresh.ExpiresAt.After(time.Now()) {
+			refresh.Token = ""
+			return &refresh, nil
+		}
+	}
+	return nil, ErrRefreshTokenNotFound
+}
+
+func (s *MemoryRefreshTokenStore) Delete(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, hashToken(token))
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) DeleteByID(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, refresh := range s.tokens {
+		if refresh.ID == id {
+			delete(s.tokens, key)
+		}
+	}
+	return nil
+}
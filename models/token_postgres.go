@@ -0,0 +1,90 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Refresh token store backed by the refresh_tokens table
+type PostgresRefreshTokenStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRefreshTokenStore(db *sql.DB) *PostgresRefreshTokenStore {
+	return &PostgresRefreshTokenStore{db: db}
+}
+
+func (s *PostgresRefreshTokenStore) Create(ctx context.Context, username string, ttl time.Duration) (*RefreshToken, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	id, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	token, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	refresh := RefreshToken{ID: id, Token: token, Username: username, ExpiresAt: time.Now().Add(ttl)}
+
+	// A rotation replaces the token in its row, so only the expired tokens
+	// are left behind. They're dropped whenever a token is issued
+	_, err = s.db.ExecContext(ctx, `with expired as (delete from refresh_tokens where expires_at <= now())
+		insert into refresh_tokens (id, token_hash, username, expires_at) values ($1, $2, $3, $4)`,
+		refresh.ID, hashToken(token), refresh.Username, refresh.ExpiresAt)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &refresh, nil
+}
+
+func (s *PostgresRefreshTokenStore) Rotate(ctx context.Context, token string, ttl time.Duration) (*RefreshToken, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	newToken, err := NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	refresh := RefreshToken{Token: newToken, ExpiresAt: time.Now().Add(ttl)}
+
+	// Swapping the hash in a single statement makes sure a token can only be
+	// exchanged once
+	err = s.db.QueryRowContext(ctx, `update refresh_tokens set token_hash = $1, expires_at = $2
+		where token_hash = $3 and expires_at > now() returning id, username`,
+		hashToken(newToken), refresh.ExpiresAt, hashToken(token)).Scan(&refresh.ID, &refresh.Username)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenNotFound
+	} else if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &refresh, nil
+}
+
+func (s *PostgresRefreshTokenStore) ByID(ctx context.Context, id string) (*RefreshToken, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	refresh := RefreshToken{ID: id}
+	err := s.db.QueryRowContext(ctx, "select username, expires_at from refresh_tokens where id = $1 and expires_at > now()",
+		id).Scan(&refresh.Username, &refresh.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenNotFound
+	} else if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &refresh, nil
+}
+
+func (s *PostgresRefreshTokenStore) Delete(ctx context.Context, token string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, "delete from refresh_tokens where token_hash = $1", hashToken(token))
+	return dbError(ctx, err)
+}
+
+func (s *PostgresRefreshTokenStore) DeleteByID(ctx context.Context, id string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, "delete from refresh_tokens where id = $1", id)
+	return dbError(ctx, err)
+}
@@ -1,58 +1,139 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	Username string `json:"username"`
-	Password string `json:"-"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+}
+
+// The roles a user can have. Customers can only shop, editors can also
+// write articles and administrators can do everything
+const (
+	RoleCustomer = "customer"
+	RoleEditor   = "editor"
+	RoleAdmin    = "admin"
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("the username isn't available")
+	ErrEmptyPassword = errors.New("the password can't be empty")
+	ErrInvalidRole   = errors.New("the role must be customer, editor or admin")
+)
+
+// The storage backing the user accounts
+type UserRepository interface {
+	// Fetch a single user by its username
+	ByUsername(ctx context.Context, username string) (*User, error)
+	// Store a new user, failing with ErrUsernameTaken if it already exists
+	Create(ctx context.Context, u *User) error
+}
+
+// The accounts available on a fresh installation, with their plain passwords
+var demoCredentials = []struct{ username, password, role string }{
+	{"user1", "pass1", RoleAdmin},
+	{"user2", "pass2", RoleEditor},
+	{"user3", "pass3", RoleCustomer},
+}
+
+// Hash compared against when the user doesn't exist, so that a failed
+// login takes the same time whether or not the username is registered
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Return the demo accounts with their passwords hashed
+func DemoUsers() []User {
+	users := []User{}
+	for _, c := range demoCredentials {
+		hash, err := HashPassword(c.password)
+		if err != nil {
+			panic(err.Error())
+		}
+		users = append(users, User{Username: c.username, PasswordHash: hash, Role: c.role})
+	}
+	return users
 }
 
-// For this demo, we're storing the user list in memory
-// We also have some users predefined.
-// In a real application, this list will most likely be fetched
-// from a database. Moreover, in production settings, you should
-// store passwords securely by salting and hashing them instead
-// of using them as we're doing in this demo
-var UserList = []User{
-	{Username: "user1", Password: "pass1"},
-	{Username: "user2", Password: "pass2"},
-	{Username: "user3", Password: "pass3"},
+// Hash a password with bcrypt so that it can be stored
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
 }
 
 // Register a new user with the given username and password
-func RegisterNewUser(username, password string) (*User, error) {
+func RegisterNewUser(ctx context.Context, users UserRepository, username, password string) (*User, error) {
+	return CreateUser(ctx, users, username, password, RoleCustomer)
+}
+
+// Create a user with the given username, password and role
+func CreateUser(ctx context.Context, users UserRepository, username, password, role string) (*User, error) {
 	if strings.TrimSpace(password) == "" {
-		return nil, errors.New("the password can't be empty")
-	} else if !IsUsernameAvailable(username) {
-		return nil, errors.New("the username isn't available")
+		return nil, ErrEmptyPassword
+	} else if !IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if available, err := IsUsernameAvailable(ctx, users, username); err != nil {
+		return nil, err
+	} else if !available {
+		return nil, ErrUsernameTaken
 	}
 
-	u := User{Username: username, Password: password}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	u := User{Username: username, PasswordHash: hash, Role: role}
 
-	UserList = append(UserList, u)
+	if err := users.Create(ctx, &u); err != nil {
+		return nil, err
+	}
 
 	return &u, nil
 }
 
-// Check if the supplied username is available
-func IsUsernameAvailable(username string) bool {
-	for _, u := range UserList {
-		if u.Username == username {
-			return false
-		}
+// Check if the role is one of the known ones
+func IsValidRole(role string) bool {
+	return role == RoleCustomer || role == RoleEditor || role == RoleAdmin
+}
+
+// Check if the supplied username is available. The error is only set when
+// the users can't be read
+func IsUsernameAvailable(ctx context.Context, users UserRepository, username string) (bool, error) {
+	_, err := users.ByUsername(ctx, username)
+	if err == ErrUserNotFound {
+		return true, nil
+	} else if err != nil {
+		return false, err
 	}
-	return true
+	return false, nil
 }
 
-//Function to validate the login credentials
-func IsUserValid(username, password string) bool {
-	for _, u := range UserList {
-		if u.Username == username && u.Password == password {
-			return true
-		}
+//Function to validate the login credentials. The error is only set when
+// the users can't be read, wrong credentials aren't an error
+func IsUserValid(ctx context.Context, users UserRepository, username, password string) (bool, error) {
+	u, err := users.ByUsername(ctx, username)
+	if err == ErrUserNotFound {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	// An empty password never matches, nor does an account without one
+	if password == "" || u.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false, nil
 	}
-	return false
+
+	// Anything but a bcrypt hash fails to compare, the plain passwords of
+	// the older setups are hashed by the migrations
+
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil, nil
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"sync"
+)
+
+// User repository keeping the accounts in memory
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users []User
+}
+
+// Create a repository holding a copy of the given users
+func NewMemoryUserRepository(users ...User) *MemoryUserRepository {
+	return &MemoryUserRepository{users: append([]User{}, users...)}
+}
+
+func (r *MemoryUserRepository) ByUsername(ctx context.Context, username string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if i := r.indexOf(username); i >= 0 {
+		u := r.users[i]
+		return &u, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, u *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.indexOf(u.Username) >= 0 {
+		return ErrUsernameTaken
+	}
+	r.users = append(r.users, *u)
+	return nil
+}
+
+// Position of the user in the list or -1. Callers must hold the lock
+func (r *MemoryUserRepository) indexOf(username string) int {
+	for i, u := range r.users {
+		if u.Username == username {
+			return i
+		}
+	}
+	return -1
+}
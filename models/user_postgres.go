@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error code raised when a unique constraint is violated
+const uniqueViolation = "23505"
+
+// User repository backed by the users table
+type PostgresUserRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+func (r *PostgresUserRepository) ByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	u := User{}
+	err := r.db.QueryRowContext(ctx, "select username, password_hash, role from users where username = $1", username).
+		Scan(&u.Username, &u.PasswordHash, &u.Role)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return &u, nil
+}
+
+func (r *PostgresUserRepository) Create(ctx context.Context, u *User) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, "insert into users (username, password_hash, role) values ($1, $2, $3)",
+		u.Username, u.PasswordHash, u.Role)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return ErrUsernameTaken
+	}
+	return dbError(ctx, err)
+}
@@ -0,0 +1,390 @@
+package routes
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The description of a route in the OpenAPI document
+type routeDoc struct {
+	summary string
+	tag     string
+	// The fields of the form POSTed to the route
+	form []string
+	// A value whose type is the one of the JSON body
+	body interface{}
+	// A value whose type is the one of the payload of the response
+	payload interface{}
+	// Whether the route belongs to the JSON API, whose responses are wrapped
+	// in an envelope
+	api bool
+	// The media type of a successful response, when it isn't a page
+	mediaType string
+	// The status of a successful response, 200 when not set
+	status int
+	// The statuses of the errors the route can answer with
+	errors []int
+}
+
+// The schema of the form fields that aren't strings. Like the path
+// parameters, the fields ending with _id are integers
+var formFieldTypes = map[string]gin.H{
+	"price":    {"type": "number", "minimum": 0},
+	"quantity": {"type": "integer", "minimum": 0},
+}
+
+// Return the schema of a form field
+func formFieldSchema(name string) gin.H {
+	if schema, ok := formFieldTypes[name]; ok {
+		return schema
+	} else if strings.HasSuffix(name, "_id") {
+		return gin.H{"type": "integer"}
+	}
+	return gin.H{"type": "string"}
+}
+
+// The description of every route, by method and path as registered in
+// InitializeRoutes. TestOpenAPIDocumentsEveryRoute fails when a route is
+// missing from here
+var routeDocs = map[string]routeDoc{
+	"GET /healthz": {summary: "Report that the application is alive and whether the database is reachable",
+		tag: "health", payload: handlers.HealthStatus{}, mediaType: "application/json"},
+	"GET /readyz": {summary: "Report whether the application can serve the requests", tag: "health",
+		payload: handlers.HealthStatus{}, mediaType: "application/json",
+		errors: []int{http.StatusServiceUnavailable}},
+
+	"GET /": {summary: "List the articles on the home page", tag: "articles",
+		payload: []models.Article{}, errors: []int{http.StatusNotAcceptable}},
+	"GET /openapi.json": {summary: "Return this OpenAPI document", tag: "documentation",
+		mediaType: "application/json"},
+	"GET /api/docs": {summary: "Browse this OpenAPI document", tag: "documentation"},
+	"GET /api/docs/assets/*filepath": {summary: "Return a file of the page browsing this OpenAPI document",
+		tag: "documentation", mediaType: "application/octet-stream", errors: []int{http.StatusNotFound}},
+	"HEAD /api/docs/assets/*filepath": {summary: "Check a file of the page browsing this OpenAPI document",
+		tag: "documentation", mediaType: "application/octet-stream", errors: []int{http.StatusNotFound}},
+
+	"GET /u/login": {summary: "Show the login page", tag: "users",
+		errors: []int{http.StatusUnauthorized}},
+	"POST /u/login": {summary: "Log in and start a session", tag: "users",
+		form: []string{"username", "password"}, errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	"GET /u/logout": {summary: "End the session", tag: "users",
+		status: http.StatusTemporaryRedirect, errors: []int{http.StatusUnauthorized}},
+	"GET /u/register": {summary: "Show the registration page", tag: "users",
+		errors: []int{http.StatusUnauthorized}},
+	"POST /u/register": {summary: "Register a customer and start a session", tag: "users",
+		form: []string{"username", "password"}, errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+
+	"GET /article/view/:article_id": {summary: "Show an article", tag: "articles",
+		payload: models.Article{}, errors: []int{http.StatusNotFound, http.StatusNotAcceptable}},
+	"GET /article/create": {summary: "Show the article creation page", tag: "articles",
+		errors: []int{http.StatusUnauthorized, http.StatusForbidden}},
+	"POST /article/create": {summary: "Create an article", tag: "articles",
+		form: []string{"title", "content"}, payload: models.Article{},
+		errors: []int{http.StatusUnauthorized, http.StatusForbidden}},
+
+	"GET /products": {summary: "List the products", tag: "products",
+		payload: []models.Product{}, errors: []int{http.StatusNotAcceptable}},
+	"GET /product/view/:product_id": {summary: "Show a product", tag: "products",
+		payload: models.Product{}, errors: []int{http.StatusNotFound, http.StatusNotAcceptable}},
+	"GET /product/create": {summary: "Show the product creation page", tag: "products",
+		errors: []int{http.StatusUnauthorized, http.StatusForbidden}},
+	"POST /product/create": {summary: "Create a product", tag: "products",
+		form: []string{"name", "description", "price", "quantity"}, payload: models.Product{},
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden}},
+	"GET /product/edit/:product_id": {summary: "Show the product edit page", tag: "products",
+		payload: models.Product{}, errors: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}},
+	"POST /product/edit/:product_id": {summary: "Update a product", tag: "products",
+		form: []string{"name", "description", "price", "quantity"}, payload: models.Product{},
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}},
+	"POST /product/delete/:product_id": {summary: "Delete a product", tag: "products",
+		payload: models.Product{}, errors: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}},
+
+	"GET /cart": {summary: "Show the cart", tag: "cart", payload: models.Cart{}},
+	"POST /cart/add": {summary: "Add a product to the cart", tag: "cart",
+		form: []string{"product_id", "quantity"}, payload: models.Cart{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"POST /cart/update": {summary: "Change the quantity of a product in the cart", tag: "cart",
+		form: []string{"product_id", "quantity"}, payload: models.Cart{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"POST /cart/remove": {summary: "Remove a product from the cart", tag: "cart",
+		form: []string{"product_id"}, payload: models.Cart{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+	"POST /checkout": {summary: "Place and pay an order for the cart", tag: "orders",
+		payload: models.Order{},
+		errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusNotFound}},
+	"POST /payments/webhook": {summary: "Receive a notification of the payment provider", tag: "orders",
+		status: http.StatusNoContent,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict}},
+	"GET /orders": {summary: "List the orders of the logged in user", tag: "orders",
+		payload: []models.Order{}, errors: []int{http.StatusUnauthorized}},
+	"GET /orders/view/:order_id": {summary: "Show an order of the logged in user", tag: "orders",
+		payload: models.Order{}, errors: []int{http.StatusUnauthorized, http.StatusNotFound}},
+	"GET /admin/orders": {summary: "List every order", tag: "admin",
+		payload: []models.Order{}, errors: []int{http.StatusUnauthorized, http.StatusForbidden}},
+	"GET /admin/orders/view/:order_id": {summary: "Show any order", tag: "admin",
+		payload: models.Order{}, errors: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}},
+	"POST /admin/orders/status/:order_id": {summary: "Change the status of an order", tag: "admin",
+		form: []string{"status"}, payload: models.Order{},
+		errors: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusConflict, http.StatusBadGateway}},
+
+	"GET /api/v1/articles": {summary: "List the articles", tag: "api", api: true,
+		payload: []models.Article{}},
+	"GET /api/v1/articles/:article_id": {summary: "Return an article", tag: "api", api: true,
+		payload: models.Article{}, errors: []int{http.StatusNotFound}},
+	"POST /api/v1/articles": {summary: "Create an article", tag: "api", api: true,
+		body: models.Article{}, payload: models.Article{}, status: http.StatusCreated,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+			http.StatusUnprocessableEntity}},
+	"GET /api/v1/products": {summary: "List the products", tag: "api", api: true,
+		payload: []models.Product{}},
+	"GET /api/v1/products/:product_id": {summary: "Return a product", tag: "api", api: true,
+		payload: models.Product{}, errors: []int{http.StatusNotFound}},
+	"POST /api/v1/products": {summary: "Create a product", tag: "api", api: true,
+		body: models.Product{}, payload: models.Product{}, status: http.StatusCreated,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+			http.StatusUnprocessableEntity}},
+	"PUT /api/v1/products/:product_id": {summary: "Replace a product", tag: "api", api: true,
+		body: models.Product{}, payload: models.Product{},
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+			http.StatusNotFound, http.StatusUnprocessableEntity}},
+	"DELETE /api/v1/products/:product_id": {summary: "Delete a product", tag: "api", api: true,
+		status: http.StatusNoContent,
+		errors: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}},
+	"POST /api/v1/users": {summary: "Register a customer", tag: "api", api: true,
+		body: handlers.APICredentials{}, payload: models.User{}, status: http.StatusCreated,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict,
+			http.StatusUnprocessableEntity}},
+	"GET /api/v1/users/me": {summary: "Return the logged in user", tag: "api", api: true,
+		payload: models.User{}, errors: []int{http.StatusUnauthorized}},
+	"POST /api/v1/auth/login": {summary: "Log in and start a session", tag: "api", api: true,
+		body: handlers.APICredentials{}, payload: models.User{},
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	"POST /api/v1/auth/logout": {summary: "End the session and revoke its refresh token", tag: "api", api: true,
+		body: handlers.APITokenRequest{}, status: http.StatusNoContent,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	"POST /api/v1/auth/token": {summary: "Issue a bearer token and a refresh token", tag: "api", api: true,
+		body: handlers.APITokenRequest{}, payload: models.TokenPair{},
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	"POST /api/v1/auth/revoke": {summary: "Revoke a refresh token and its bearer tokens", tag: "api", api: true,
+		body: handlers.APITokenRequest{}, status: http.StatusNoContent, errors: []int{http.StatusBadRequest}},
+	"GET /api/v1/keys": {summary: "List the API keys of the logged in user", tag: "api", api: true,
+		payload: []models.APIKey{}, errors: []int{http.StatusUnauthorized, http.StatusForbidden}},
+	"POST /api/v1/keys": {summary: "Create an API key, returned only this once", tag: "api", api: true,
+		body: handlers.APIKeyRequest{}, payload: models.APIKey{}, status: http.StatusCreated,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+			http.StatusUnprocessableEntity}},
+	"DELETE /api/v1/keys/:key_id": {summary: "Revoke an API key", tag: "api", api: true,
+		status: http.StatusNoContent,
+		errors: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}},
+}
+
+// Build the OpenAPI document describing the routes. The routes missing from
+// routeDocs are returned as well, they only get their parameters described
+func OpenAPIDocument(routes gin.RoutesInfo) (gin.H, []string) {
+	schemas := schemaRegistry{}
+	paths := gin.H{}
+	undocumented := []string{}
+
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		doc, ok := routeDocs[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+		}
+
+		path, params := openAPIPath(route.Path)
+		item, _ := paths[path].(gin.H)
+		if item == nil {
+			item = gin.H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = doc.operation(schemas, params)
+	}
+	sort.Strings(undocumented)
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":   "GolangStore",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		// The routes needing a logged in user accept either of these
+		"security": []gin.H{{}, {"bearerAuth": []string{}}, {"cookieAuth": []string{}}, {"apiKeyAuth": []string{}}},
+		"components": gin.H{
+			"schemas": schemas,
+			"securitySchemes": gin.H{
+				"bearerAuth": gin.H{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"cookieAuth": gin.H{"type": "apiKey", "in": "cookie", "name": "token"},
+				"apiKeyAuth": gin.H{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}, undocumented
+}
+
+// Describe the route as an OpenAPI operation
+func (d routeDoc) operation(schemas schemaRegistry, params []gin.H) gin.H {
+	op := gin.H{"responses": d.responses(schemas)}
+	if d.summary != "" {
+		op["summary"] = d.summary
+	}
+	if d.tag != "" {
+		op["tags"] = []string{d.tag}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if len(d.form) > 0 {
+		fields := gin.H{}
+		for _, name := range d.form {
+			fields[name] = formFieldSchema(name)
+		}
+		op["requestBody"] = gin.H{"required": true, "content": gin.H{
+			"application/x-www-form-urlencoded": gin.H{"schema": gin.H{"type": "object", "properties": fields}},
+		}}
+	} else if d.body != nil {
+		op["requestBody"] = gin.H{"required": true, "content": gin.H{
+			"application/json": gin.H{"schema": schemas.ref(reflect.TypeOf(d.body))},
+		}}
+	}
+	return op
+}
+
+// Describe the responses of the route, successful or not
+func (d routeDoc) responses(schemas schemaRegistry) gin.H {
+	status := d.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := gin.H{"description": http.StatusText(status)}
+
+	if d.mediaType != "" {
+		schema := gin.H{"type": "object"}
+		if d.payload != nil {
+			schema = schemas.ref(reflect.TypeOf(d.payload))
+		}
+		success["content"] = gin.H{d.mediaType: gin.H{"schema": schema}}
+	} else if d.api && status != http.StatusNoContent {
+		// The API wraps its payload in the data field
+		envelope := gin.H{"type": "object", "properties": gin.H{"data": schemas.ref(reflect.TypeOf(d.payload))}}
+		success["content"] = gin.H{"application/json": gin.H{"schema": envelope}}
+	} else if !d.api && status == http.StatusOK {
+		// The pages can be rendered in the other formats
+		content := gin.H{"text/html": gin.H{"schema": gin.H{"type": "string"}}}
+		if d.payload != nil {
+			payload := schemas.ref(reflect.TypeOf(d.payload))
+			content["application/json"] = gin.H{"schema": payload}
+			content["application/xml"] = gin.H{"schema": payload}
+			if reflect.TypeOf(d.payload).Kind() == reflect.Slice {
+				content["text/csv"] = gin.H{"schema": gin.H{"type": "string"}}
+			}
+		}
+		success["content"] = content
+	}
+
+	responses := gin.H{strconv.Itoa(status): success}
+	for _, code := range d.errors {
+		response := gin.H{"description": http.StatusText(code)}
+		if d.api {
+			response["content"] = gin.H{"application/json": gin.H{
+				"schema": schemas.ref(reflect.TypeOf(middleware.APIError{})),
+			}}
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+	return responses
+}
+
+// Turn a gin path into an OpenAPI one, along with its parameters. The
+// parameters named like IDs are integers
+func openAPIPath(path string) (string, []gin.H) {
+	segments := strings.Split(path, "/")
+	params := []gin.H{}
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+
+		schema := gin.H{"type": "string"}
+		if strings.HasSuffix(name, "_id") {
+			schema = gin.H{"type": "integer"}
+		}
+		params = append(params, gin.H{"name": name, "in": "path", "required": true, "schema": schema})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// The schemas of the structs used by the routes, by name
+type schemaRegistry map[string]gin.H
+
+// Return the schema of a type, adding the structs it's made of to the
+// registry and referencing them
+func (s schemaRegistry) ref(t reflect.Type) gin.H {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.ref(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return gin.H{"type": "array", "items": s.ref(t.Elem())}
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return gin.H{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return gin.H{"type": "string", "format": "date-time"}
+		}
+		if _, ok := s[t.Name()]; !ok {
+			// Register the name first so that recursive types terminate
+			s[t.Name()] = gin.H{}
+			properties := gin.H{}
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				name := strings.Split(field.Tag.Get("json"), ",")[0]
+				if field.PkgPath != "" || name == "-" {
+					continue
+				} else if name == "" {
+					name = field.Name
+				}
+				properties[name] = s.ref(field.Type)
+			}
+			s[t.Name()] = gin.H{"type": "object", "properties": properties}
+		}
+		return gin.H{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return gin.H{"type": "string"}
+}
+
+// handler returning the OpenAPI document of the routes of the router
+func serveOpenAPI(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doc, _ := OpenAPIDocument(router.Routes())
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// handler showing the page browsing the OpenAPI document with Swagger UI.
+// Its files are bundled, so it works offline
+func showAPIDocs(c *gin.Context) {
+	c.HTML(http.StatusOK, "api-docs.html", gin.H{
+		"title":      "API Documentation",
+		"spec":       "/openapi.json",
+		"assets":     "/api/docs/assets",
+		"csrf_token": c.GetString("csrf_token")})
+}
@@ -1,45 +1,157 @@
 package routes
 
 import (
+	"GolangStore/config"
+	"GolangStore/database"
 	"GolangStore/handlers"
 	"GolangStore/middleware"
+	"GolangStore/models"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 )
 
-var router *gin.Engine
-
-func GinSetup() {
-	// Set Gin to production mode
-	//gin.SetMode(gin.ReleaseMode)
+func GinSetup(cfg *config.Config) {
+	// Set the mode of Gin, release in production
+	gin.SetMode(cfg.Mode)
 
 	// Set the router as the default one provided by Gin
-	router = gin.Default()
+	router := gin.Default()
 
 	// Process the templates at the start so that they don't have to be loaded
 	// from the disk again. This makes serving HTML pages very fast.
 	router.LoadHTMLGlob("templates/*")
 
+	// Stop on SIGINT or SIGTERM. A second signal kills the process right away.
+	// The signals also interrupt the attempts to reach the database
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Println("Shutting down")
+	}()
+
+	// Open the database pool once and share it between the repositories. The
+	// startup fails when the database can't be reached
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		log.Fatal("Unable to connect: " + err.Error())
+	}
+	handlers.Database = db
+
+	// Bring the schema up to date when asked to. Otherwise it's done by the
+	// migrate command
+	if cfg.Database.MigrateOnStart {
+		applied, err := database.MigrateUp(ctx, db)
+		if err != nil {
+			log.Fatal("Unable to migrate the database: " + err.Error())
+		}
+		for _, m := range applied {
+			log.Printf("Applied the migration %d_%s", m.Version, m.Name)
+		}
+	}
+	models.QueryTimeout = cfg.Database.QueryTimeout
+	handlers.Products = models.NewPostgresProductRepository(db)
+	handlers.Articles = models.NewPostgresArticleRepository(db)
+	handlers.Users = models.NewPostgresUserRepository(db)
+	handlers.Sessions = models.NewPostgresSessionStore(db)
+	handlers.Tokens = models.NewTokenIssuer(tokenSecret(cfg.Auth), models.NewPostgresRefreshTokenStore(db))
+	handlers.APIKeys = models.NewPostgresAPIKeyRepository(db)
+	handlers.Carts = models.NewPostgresCartRepository(db)
+	handlers.Orders = models.NewPostgresOrderRepository(db)
+
+	// Only the fake payment provider is available for now. Its mode makes it
+	// succeed, decline or time out
+	payments := models.NewFakePaymentGateway(cfg.Payments.FakeMode, cfg.Payments.WebhookSecret)
+	payments.TimeoutDelay = cfg.Payments.FakeTimeout
+	handlers.Payments = payments
+	if cfg.Payments.WebhookSecret == "" {
+		log.Println("The payment webhooks are refused until payments.webhook_secret is set")
+	}
+
+	// The cookies are only sent over HTTPS when they are secure
+	handlers.SecureCookies = cfg.Cookies.Secure
+	handlers.CookieSameSite = cfg.Cookies.SameSiteMode()
+
 	// Initialize the routes
-	initializeRoutes()
+	InitializeRoutes(router)
+
+	// Start serving the application. The format suffixes of the URLs are
+	// handled before the requests reach the router
+	srv := &http.Server{
+		Handler:           middleware.FormatSuffix(router),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	ln, err := net.Listen("tcp", cfg.Server.Address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Listening on " + ln.Addr().String())
 
-	// Start serving the application
-	router.Run()
+	if err := Serve(ctx, srv, ln, cfg.Server.ShutdownTimeout); err != nil {
+		log.Println("The server stopped: " + err.Error())
+	}
+
+	// The pool is only closed once no request can use it anymore
+	if err := db.Close(); err != nil {
+		log.Println("Unable to close the database pool: " + err.Error())
+	}
 }
 
-func initializeRoutes() {
+// The path of the version of the JSON API
+const apiPrefix = "/api/v1"
+
+// Register every route of the application on the router. Each of them needs
+// an entry in routeDocs to be described by the OpenAPI document
+func InitializeRoutes(router *gin.Engine) {
+
+	// Handle GET requests at /healthz and /readyz to check the health of the
+	// application. They're registered before the middlewares below so that
+	// they don't need a session lookup
+	router.GET("/healthz", handlers.Healthz)
+	router.GET("/readyz", handlers.Readyz)
+
+	// Mark the requests of the JSON API before the other middlewares, so
+	// that the requests they reject get a JSON error body as well
+	router.Use(middleware.APIPrefix(apiPrefix))
+
+	// Answer the API requests matching no route with a JSON error as well
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(middleware.APIFallback(http.StatusNotFound))
+	router.NoMethod(middleware.APIFallback(http.StatusMethodNotAllowed))
 
 	// Use the setUserStatus middleware for every route to set a flag
 	// indicating whether the request was from an authenticated user or not
-	router.Use(middleware.SetUserStatus())
+	router.Use(middleware.SetUserStatus(handlers.Sessions, handlers.Tokens, handlers.Users))
+
+	// Check the CSRF token of the forms POSTed by the browsers. The payment
+	// provider signs its webhooks instead
+	router.Use(middleware.CSRF(handlers.SecureCookies, handlers.CookieSameSite, "/payments/webhook"))
 
 	// Handle the index route
 	router.GET("/", handlers.ShowIndexPage)
+
+	// Handle GET requests at /openapi.json and describe the routes
+	router.GET("/openapi.json", serveOpenAPI(router))
+	// Handle GET requests at /api/docs and show the page browsing the description
+	router.GET("/api/docs", showAPIDocs)
+	// Handle GET requests at /api/docs/assets and serve the files of Swagger UI
+	router.StaticFS("/api/docs/assets", swaggerUIAssets())
 	/* Grouping routes together allows you to apply middleware on all
 	   routes in a group instead of doing so separately for each route. */
 	/*
 		EnsureNotLoggedIn -> Ensures that the user is not logged in by using the middleware function
 		EnsureLoggedIn    -> Ensure that the user is logged in by using the middleware
+		RequireRole       -> Ensure that the logged in user has one of the given roles
 	*/
 	// Group user related routes together
 	userRoutes := router.Group("/u")
@@ -62,13 +174,133 @@ func initializeRoutes() {
 		// Handle GET requests at /article/view/some_article_id
 		articleRoutes.GET("/view/:article_id", handlers.GetArticle)
 		// Handle the GET requests at /article/create Show the article creation
-		articleRoutes.GET("/create", middleware.EnsureLoggedIn(), handlers.ShowArticleCreationPage)
+		articleRoutes.GET("/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.ShowArticleCreationPage)
 		// Handle POST requests at /article/create
-		articleRoutes.POST("/create", middleware.EnsureLoggedIn(), handlers.CreateArticle)
+		articleRoutes.POST("/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.CreateArticle)
 		// articleRoutes.GET("/delete/:article_id", middleware.EnsureLoggedIn(), handlers.DeleteArticlePage)
 		// articleRoutes.GET("/delete", middleware.EnsureLoggedIn(), handlers.ShowArticleDeletePage)
 	}
 
 	router.GET("/products", handlers.IndexPage)
 
+	// Group product related routes together
+	productRoutes := router.Group("/product")
+	{
+		// Handle GET requests at /product/view/some_product_id
+		productRoutes.GET("/view/:product_id", handlers.GetProduct)
+		// Handle the GET requests at /product/create and show the product creation page
+		productRoutes.GET("/create", middleware.RequireRole(models.RoleAdmin), handlers.ShowProductCreationPage)
+		// Handle POST requests at /product/create
+		productRoutes.POST("/create", middleware.RequireRole(models.RoleAdmin), handlers.CreateProduct)
+		// Handle the GET requests at /product/edit/some_product_id and show the edit page
+		productRoutes.GET("/edit/:product_id", middleware.RequireRole(models.RoleAdmin), handlers.ShowProductEditPage)
+		// Handle POST requests at /product/edit/some_product_id
+		productRoutes.POST("/edit/:product_id", middleware.RequireRole(models.RoleAdmin), handlers.UpdateProduct)
+		// Handle POST requests at /product/delete/some_product_id
+		productRoutes.POST("/delete/:product_id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteProduct)
+	}
+
+	// Group cart related routes together. Anonymous visitors have a cart as well
+	cartRoutes := router.Group("/cart")
+	{
+		// Handle GET requests at /cart and show the cart
+		cartRoutes.GET("", handlers.ShowCart)
+		// Handle POST requests at /cart/add
+		cartRoutes.POST("/add", handlers.AddToCart)
+		// Handle POST requests at /cart/update
+		cartRoutes.POST("/update", handlers.UpdateCart)
+		// Handle POST requests at /cart/remove
+		cartRoutes.POST("/remove", handlers.RemoveFromCart)
+	}
+
+	// Handle POST requests at /checkout and turn the cart into an order
+	router.POST("/checkout", middleware.EnsureLoggedIn(), handlers.Checkout)
+
+	// Handle POST requests at /payments/webhook sent by the payment provider
+	router.POST("/payments/webhook", handlers.PaymentWebhook)
+
+	// Group the routes showing the orders of the logged in user together
+	orderRoutes := router.Group("/orders", middleware.EnsureLoggedIn())
+	{
+		// Handle GET requests at /orders and list the orders
+		orderRoutes.GET("", handlers.ShowOrders)
+		// Handle GET requests at /orders/view/some_order_id
+		orderRoutes.GET("/view/:order_id", handlers.GetOrder)
+	}
+
+	// Group the administration routes together. Only administrators can use them
+	adminRoutes := router.Group("/admin", middleware.RequireRole(models.RoleAdmin))
+	{
+		// Handle GET requests at /admin/orders and list every order
+		adminRoutes.GET("/orders", handlers.ShowAdminOrders)
+		// Handle GET requests at /admin/orders/view/some_order_id
+		adminRoutes.GET("/orders/view/:order_id", handlers.GetAdminOrder)
+		// Handle POST requests at /admin/orders/status/some_order_id
+		adminRoutes.POST("/orders/status/:order_id", handlers.UpdateOrderStatus)
+	}
+
+	// Group the routes of the JSON API together. They always answer with JSON,
+	// errors included, and keep their contract within a version. Besides the
+	// cookie and bearer tokens, the API accepts the API keys of the users
+	/*
+		RequireScope    -> Ensure that an API key was granted the scope
+		EnsureNotAPIKey -> Ensure that the user didn't authenticate with an API key
+	*/
+	apiRoutes := router.Group(apiPrefix, middleware.API(), middleware.SetAPIKeyUser(handlers.APIKeys, handlers.Users))
+	{
+		// Handle GET requests at /api/v1/articles and list the articles
+		apiRoutes.GET("/articles", middleware.RequireScope(models.ScopeReadArticles), handlers.APIGetArticles)
+		// Handle GET requests at /api/v1/articles/some_article_id
+		apiRoutes.GET("/articles/:article_id", middleware.RequireScope(models.ScopeReadArticles), handlers.APIGetArticle)
+		// Handle POST requests at /api/v1/articles
+		apiRoutes.POST("/articles", middleware.RequireRole(models.RoleEditor, models.RoleAdmin),
+			middleware.RequireScope(models.ScopeWriteArticles), handlers.APICreateArticle)
+
+		// Handle GET requests at /api/v1/products and list the products
+		apiRoutes.GET("/products", middleware.RequireScope(models.ScopeReadProducts), handlers.APIGetProducts)
+		// Handle GET requests at /api/v1/products/some_product_id
+		apiRoutes.GET("/products/:product_id", middleware.RequireScope(models.ScopeReadProducts), handlers.APIGetProduct)
+		// Handle POST requests at /api/v1/products
+		apiRoutes.POST("/products", middleware.RequireRole(models.RoleAdmin),
+			middleware.RequireScope(models.ScopeWriteProducts), handlers.APICreateProduct)
+		// Handle PUT requests at /api/v1/products/some_product_id
+		apiRoutes.PUT("/products/:product_id", middleware.RequireRole(models.RoleAdmin),
+			middleware.RequireScope(models.ScopeWriteProducts), handlers.APIUpdateProduct)
+		// Handle DELETE requests at /api/v1/products/some_product_id
+		apiRoutes.DELETE("/products/:product_id", middleware.RequireRole(models.RoleAdmin),
+			middleware.RequireScope(models.ScopeWriteProducts), handlers.APIDeleteProduct)
+
+		// Handle POST requests at /api/v1/users and register a customer
+		apiRoutes.POST("/users", middleware.EnsureNotLoggedIn(), handlers.APIRegister)
+		// Handle GET requests at /api/v1/users/me and return the logged in user
+		apiRoutes.GET("/users/me", middleware.EnsureLoggedIn(), middleware.RequireScope(models.ScopeReadProfile),
+			handlers.APIGetCurrentUser)
+
+		// Handle POST requests at /api/v1/auth/login
+		apiRoutes.POST("/auth/login", middleware.EnsureNotLoggedIn(), handlers.APILogin)
+		// Handle POST requests at /api/v1/auth/logout
+		apiRoutes.POST("/auth/logout", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APILogout)
+		// Handle POST requests at /api/v1/auth/token and issue bearer tokens
+		apiRoutes.POST("/auth/token", handlers.APIToken)
+		// Handle POST requests at /api/v1/auth/revoke and revoke a refresh token
+		apiRoutes.POST("/auth/revoke", handlers.APIRevokeToken)
+
+		// Handle GET requests at /api/v1/keys and list the API keys of the user
+		apiRoutes.GET("/keys", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APIGetKeys)
+		// Handle POST requests at /api/v1/keys and create an API key
+		apiRoutes.POST("/keys", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APICreateKey)
+		// Handle DELETE requests at /api/v1/keys/some_key_id and revoke the key
+		apiRoutes.DELETE("/keys/:key_id", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APIDeleteKey)
+	}
+
+}
+
+// Return the secret signing the access tokens of the API. Without one a
+// random secret is used, which logs out every API client on restart
+func tokenSecret(cfg config.AuthConfig) []byte {
+	if cfg.TokenSecret != "" {
+		return []byte(cfg.TokenSecret)
+	}
+	log.Println("No token secret is configured, the bearer tokens won't survive a restart")
+	return models.RandomTokenSecret()
 }
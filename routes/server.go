@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Serve the requests accepted by the listener until the context is done.
+// The server then stops accepting connections and waits for the requests in
+// progress for up to shutdownTimeout. It returns the error that stopped the
+// server, or the one of the shutdown, like context.DeadlineExceeded when
+// some requests were cut short
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Cut the remaining connections short
+		srv.Close()
+		return err
+	}
+	return nil
+}
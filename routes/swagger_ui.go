@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// The files of Swagger UI, browsing the OpenAPI document. They're
+// embedded so that the documentation can be browsed offline, see
+// swagger-ui/README.md
+//
+//go:embed swagger-ui
+var swaggerUIFiles embed.FS
+
+// The Swagger UI files, as served at /api/docs/assets
+func swaggerUIAssets() http.FileSystem {
+	assets, err := fs.Sub(swaggerUIFiles, "swagger-ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.FS(assets)
+}
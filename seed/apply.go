@@ -0,0 +1,176 @@
+package seed
+
+import (
+	"GolangStore/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// The repositories the fixtures are stored in, Postgres or in-memory ones
+type Target struct {
+	Users      models.UserRepository
+	Articles   models.ArticleRepository
+	Products   models.ProductRepository
+	Categories models.CategoryRepository
+	Orders     models.OrderRepository
+}
+
+// How many records Apply stored
+type Summary struct {
+	Users      int
+	Articles   int
+	Products   int
+	Categories int
+	Orders     int
+}
+
+// Returned by Apply when the target already holds articles, products,
+// categories or orders, whose IDs would then differ from run to run. Reset
+// empties a database that holds some
+var ErrNotEmpty = errors.New("the database already holds data, seed an empty one")
+
+// The statuses an order goes through after being placed to reach a status
+var statusPaths = map[string][]string{
+	models.OrderPending:   {},
+	models.OrderPaid:      {models.OrderPaid},
+	models.OrderShipped:   {models.OrderPaid, models.OrderShipped},
+	models.OrderDelivered: {models.OrderPaid, models.OrderShipped, models.OrderDelivered},
+	models.OrderCancelled: {models.OrderCancelled},
+	models.OrderRefunded:  {models.OrderPaid, models.OrderRefunded},
+}
+
+// The name the status changes made by the seed are recorded under
+const seedActor = "seed"
+
+// Store the fixture. The users that already exist are left as they are,
+// everything else needs an empty target so that the records get the same
+// IDs every time. The records are not stored in one transaction, a run that
+// fails halfway leaves some behind and is started over after a Reset
+func Apply(ctx context.Context, f *Fixture, t Target) (*Summary, error) {
+	if err := ensureEmpty(ctx, t); err != nil {
+		return nil, err
+	}
+	summary := &Summary{}
+
+	for _, u := range f.Users {
+		_, err := models.CreateUser(ctx, t.Users, u.Username, u.Password, u.Role)
+		if err == models.ErrUsernameTaken {
+			continue
+		} else if err != nil {
+			return summary, fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		summary.Users++
+	}
+
+	for _, a := range f.Articles {
+		article := models.Article{Title: a.Title, Content: a.Content}
+		if err := article.Validate(); err != nil {
+			return summary, fmt.Errorf("article %q: %w", a.Title, err)
+		}
+		if err := t.Articles.Create(ctx, &article); err != nil {
+			return summary, err
+		}
+		summary.Articles++
+	}
+
+	productIDs := map[string]int{}
+	for _, p := range f.Products {
+		if _, ok := productIDs[p.Name]; ok {
+			return summary, fmt.Errorf("product %q: the name is used twice", p.Name)
+		}
+		product := models.Product{Name: p.Name, Description: p.Description, Price: p.Price, Quantity: p.Quantity}
+		if err := t.Products.Create(ctx, &product); err != nil {
+			return summary, fmt.Errorf("product %q: %w", p.Name, err)
+		}
+		productIDs[product.Name] = product.Id
+		summary.Products++
+	}
+
+	for _, c := range f.Categories {
+		category := models.Category{Name: c.Name, ProductIDs: []int{}}
+		for _, name := range c.Products {
+			id, ok := productIDs[name]
+			if !ok {
+				return summary, fmt.Errorf("category %q: unknown product %q", c.Name, name)
+			}
+			category.ProductIDs = append(category.ProductIDs, id)
+		}
+		if err := t.Categories.Create(ctx, &category); err != nil {
+			return summary, fmt.Errorf("category %q: %w", c.Name, err)
+		}
+		summary.Categories++
+	}
+
+	for i, o := range f.Orders {
+		if err := placeOrder(ctx, t.Orders, o, productIDs); err != nil {
+			return summary, fmt.Errorf("order %d: %w", i+1, err)
+		}
+		summary.Orders++
+	}
+	return summary, nil
+}
+
+// The tables holding the records that Apply needs to be empty. Truncating
+// them empties the tables referencing them too, such as the order items
+const resetStatement = "truncate articles, products, categories, orders restart identity cascade"
+
+// Delete the articles, products, categories and orders of a Postgres
+// database, along with everything referencing them, and start their IDs
+// over. The users are kept. This lets Apply run again after a seed that
+// failed halfway
+func Reset(ctx context.Context, db models.Queryer) error {
+	_, err := db.ExecContext(ctx, resetStatement)
+	return err
+}
+
+// Check that the target holds no record whose ID would depend on it
+func ensureEmpty(ctx context.Context, t Target) error {
+	articles, err := t.Articles.All(ctx)
+	if err != nil {
+		return err
+	}
+	products, err := t.Products.All(ctx)
+	if err != nil {
+		return err
+	}
+	categories, err := t.Categories.All(ctx)
+	if err != nil {
+		return err
+	}
+	orders, err := t.Orders.All(ctx)
+	if err != nil {
+		return err
+	}
+	if len(articles)+len(products)+len(categories)+len(orders) > 0 {
+		return ErrNotEmpty
+	}
+	return nil
+}
+
+// Place the order then move it to its status
+func placeOrder(ctx context.Context, orders models.OrderRepository, o Order, productIDs map[string]int) error {
+	path, ok := statusPaths[o.Status]
+	if !ok {
+		return fmt.Errorf("unknown status %q", o.Status)
+	}
+	entries := []models.CartEntry{}
+	for _, item := range o.Items {
+		id, ok := productIDs[item.Product]
+		if !ok {
+			return fmt.Errorf("unknown product %q", item.Product)
+		}
+		entries = append(entries, models.CartEntry{ProductID: id, Quantity: item.Quantity})
+	}
+
+	order, err := orders.Place(ctx, o.Username, entries)
+	if err != nil {
+		return err
+	}
+	for _, status := range path {
+		if _, err := orders.UpdateStatus(ctx, order.ID, status, seedActor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package seed
+
+import (
+	_ "embed"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The data put in a database by the seed command. The categories and the
+// orders refer to the products by name, as their IDs are only known once
+// they're stored
+type Fixture struct {
+	Users      []User     `yaml:"users" json:"users"`
+	Articles   []Article  `yaml:"articles" json:"articles"`
+	Products   []Product  `yaml:"products" json:"products"`
+	Categories []Category `yaml:"categories" json:"categories"`
+	Orders     []Order    `yaml:"orders" json:"orders"`
+}
+
+// A user with its plain password, hashed when stored
+type User struct {
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	Role     string `yaml:"role" json:"role"`
+}
+
+type Article struct {
+	Title   string `yaml:"title" json:"title"`
+	Content string `yaml:"content" json:"content"`
+}
+
+type Product struct {
+	Name        string  `yaml:"name" json:"name"`
+	Description string  `yaml:"description" json:"description"`
+	Price       float64 `yaml:"price" json:"price"`
+	Quantity    int     `yaml:"quantity" json:"quantity"`
+}
+
+type Category struct {
+	Name     string   `yaml:"name" json:"name"`
+	Products []string `yaml:"products" json:"products"`
+}
+
+// An order placed by the user then moved to the status
+type Order struct {
+	Username string      `yaml:"username" json:"username"`
+	Status   string      `yaml:"status" json:"status"`
+	Items    []OrderItem `yaml:"items" json:"items"`
+}
+
+type OrderItem struct {
+	Product  string `yaml:"product" json:"product"`
+	Quantity int    `yaml:"quantity" json:"quantity"`
+}
+
+// The data of a fresh development installation
+//
+//go:embed demo.yaml
+var demoFixture []byte
+
+// Return the data of a fresh development installation
+func Demo() (*Fixture, error) {
+	return Parse(demoFixture)
+}
+
+// Read a fixture from a YAML or JSON file
+func Load(path string) (*Fixture, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(content)
+}
+
+// Decode a fixture written in YAML or JSON. Unknown keys are rejected so
+// that a typo doesn't silently leave data out
+func Parse(content []byte) (*Fixture, error) {
+	var f Fixture
+	if err := yaml.UnmarshalStrict(content, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
@@ -0,0 +1,168 @@
+package seed
+
+import (
+	"GolangStore/models"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// How much data Generate makes
+type Size struct {
+	Users      int
+	Articles   int
+	Products   int
+	Categories int
+	Orders     int
+}
+
+// The size used when none is given
+var DefaultSize = Size{Users: 20, Articles: 10, Products: 50, Categories: 6, Orders: 40}
+
+// The words the names and the texts are made of
+var (
+	firstNames = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi", "ivan", "judy",
+		"mallory", "nina", "oscar", "peggy", "quentin", "rupert", "sybil", "trent", "ursula", "victor", "walter"}
+	adjectives = []string{"Compact", "Sturdy", "Elegant", "Portable", "Vintage", "Modern", "Rustic", "Deluxe",
+		"Classic", "Foldable", "Wireless", "Handmade"}
+	nouns = []string{"Lamp", "Chair", "Desk", "Notebook", "Mug", "Backpack", "Shelf", "Clock", "Speaker",
+		"Blanket", "Kettle", "Umbrella", "Cushion", "Mirror", "Rug"}
+	categoryNames = []string{"Lighting", "Furniture", "Office", "Kitchen", "Travel", "Decoration", "Electronics",
+		"Textiles", "Outdoor", "Gifts"}
+	words = []string{"quality", "design", "everyday", "comfort", "durable", "light", "practical", "material",
+		"home", "style", "simple", "warm", "bright", "soft", "solid", "careful", "made", "use", "great", "small"}
+)
+
+// The statuses of the generated orders, along with their weight
+var orderStatuses = []struct {
+	status string
+	weight int
+}{
+	{models.OrderPending, 2}, {models.OrderPaid, 3}, {models.OrderShipped, 2},
+	{models.OrderDelivered, 5}, {models.OrderCancelled, 1}, {models.OrderRefunded, 1},
+}
+
+// Make up a fixture of the given size. The same seed always gives the same
+// fixture. The first user is an administrator and the second one an editor,
+// the other ones are customers. The password of each user is its username
+// followed by "-pass"
+func Generate(seed int64, size Size) *Fixture {
+	r := rand.New(rand.NewSource(seed))
+	f := &Fixture{
+		Users:      []User{},
+		Articles:   []Article{},
+		Products:   []Product{},
+		Categories: []Category{},
+		Orders:     []Order{},
+	}
+
+	for i := 0; i < size.Users; i++ {
+		username := fmt.Sprintf("%s%d", firstNames[i%len(firstNames)], i+1)
+		role := models.RoleCustomer
+		if i == 0 {
+			role = models.RoleAdmin
+		} else if i == 1 {
+			role = models.RoleEditor
+		}
+		f.Users = append(f.Users, User{Username: username, Password: username + "-pass", Role: role})
+	}
+
+	for i := 0; i < size.Articles; i++ {
+		f.Articles = append(f.Articles, Article{
+			Title:   capitalize(sentence(r, 3+r.Intn(4))),
+			Content: paragraph(r, 2+r.Intn(4)),
+		})
+	}
+
+	// The stock is large enough for the orders below
+	for i := 0; i < size.Products; i++ {
+		name := adjectives[r.Intn(len(adjectives))] + " " + strings.ToLower(nouns[r.Intn(len(nouns))])
+		f.Products = append(f.Products, Product{
+			Name:        fmt.Sprintf("%s %d", name, i+1),
+			Description: paragraph(r, 1),
+			Price:       math.Round((1+r.Float64()*199)*100) / 100,
+			Quantity:    size.Orders*3 + r.Intn(100),
+		})
+	}
+
+	// Every product belongs to one category, some of them to a second one
+	for i := 0; i < size.Categories && len(f.Products) > 0; i++ {
+		name := categoryNames[i%len(categoryNames)]
+		if i >= len(categoryNames) {
+			name = fmt.Sprintf("%s %d", name, i/len(categoryNames)+1)
+		}
+		f.Categories = append(f.Categories, Category{Name: name, Products: []string{}})
+	}
+	for _, p := range f.Products {
+		if len(f.Categories) == 0 {
+			break
+		}
+		first := r.Intn(len(f.Categories))
+		f.Categories[first].Products = append(f.Categories[first].Products, p.Name)
+		if second := r.Intn(len(f.Categories)); second != first && r.Intn(4) == 0 {
+			f.Categories[second].Products = append(f.Categories[second].Products, p.Name)
+		}
+	}
+
+	// The orders are placed by the customers, or by the other users when
+	// there's no customer
+	buyers := f.Users
+	if len(buyers) > 2 {
+		buyers = buyers[2:]
+	}
+	for i := 0; i < size.Orders && len(buyers) > 0 && len(f.Products) > 0; i++ {
+		order := Order{Username: buyers[r.Intn(len(buyers))].Username, Status: orderStatus(r)}
+		for _, p := range r.Perm(len(f.Products))[:1+r.Intn(min(3, len(f.Products)))] {
+			order.Items = append(order.Items, OrderItem{Product: f.Products[p].Name, Quantity: 1 + r.Intn(3)})
+		}
+		f.Orders = append(f.Orders, order)
+	}
+	return f
+}
+
+// Pick the status of an order according to the weights
+func orderStatus(r *rand.Rand) string {
+	total := 0
+	for _, s := range orderStatuses {
+		total += s.weight
+	}
+	n := r.Intn(total)
+	for _, s := range orderStatuses {
+		if n < s.weight {
+			return s.status
+		}
+		n -= s.weight
+	}
+	return models.OrderPending
+}
+
+// Make up a sentence of the given number of words, without a final period
+func sentence(r *rand.Rand, length int) string {
+	picked := make([]string, length)
+	for i := range picked {
+		picked[i] = words[r.Intn(len(words))]
+	}
+	return strings.Join(picked, " ")
+}
+
+// Make up a paragraph of the given number of sentences
+func paragraph(r *rand.Rand, sentences int) string {
+	picked := make([]string, sentences)
+	for i := range picked {
+		picked[i] = capitalize(sentence(r, 5+r.Intn(8))) + "."
+	}
+	return strings.Join(picked, " ")
+}
+
+// Upper case the first letter of the text
+func capitalize(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,445 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"GolangStore/routes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Test that the API lists the articles inside the data envelope
+func TestAPIGetArticles(t *testing.T) {
+	r := getAPIRouter()
+
+	w := serveAPIRequest(r, "GET", "/api/v1/articles", "", nil)
+
+	var body struct{ Data []models.Article }
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil || len(body.Data) < 2 {
+		t.Fail()
+	}
+}
+
+// Test that missing articles are reported with a typed error body
+func TestAPIGetArticleNotFound(t *testing.T) {
+	r := getAPIRouter()
+
+	for _, url := range []string{"/api/v1/articles/999", "/api/v1/articles/abc"} {
+		w := serveAPIRequest(r, "GET", url, "", nil)
+		if w.Code != http.StatusNotFound || apiErrorCode(w) != middleware.ErrCodeNotFound {
+			t.Errorf("%s: got %d %s", url, w.Code, w.Body.String())
+		}
+	}
+}
+
+// Test the statuses of the article creation through the API
+func TestAPICreateArticle(t *testing.T) {
+	saveLists()
+	r := getAPIRouter()
+	editor := getSessionCookieFor(t, "user2")
+
+	w := serveAPIRequest(r, "POST", "/api/v1/articles", `{"title":"API Article","content":"Body"}`, editor)
+	var body struct{ Data models.Article }
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &body) != nil ||
+		body.Data.Title != "API Article" || w.Header().Get("Location") != "/api/v1/articles/3" {
+		t.Errorf("create: got %d %s", w.Code, w.Body.String())
+	}
+
+	for _, tc := range []struct {
+		payload string
+		cookie  *http.Cookie
+		status  int
+		code    string
+	}{
+		{`{"title":" ","content":"Body"}`, editor, http.StatusUnprocessableEntity, middleware.ErrCodeValidation},
+		{`{"title":`, editor, http.StatusBadRequest, middleware.ErrCodeBadRequest},
+		{`{"title":"Title","content":"Body"}`, nil, http.StatusUnauthorized, middleware.ErrCodeUnauthorized},
+		{`{"title":"Title","content":"Body"}`, getSessionCookieFor(t, "user3"), http.StatusForbidden, middleware.ErrCodeForbidden},
+	} {
+		w := serveAPIRequest(r, "POST", "/api/v1/articles", tc.payload, tc.cookie)
+		if w.Code != tc.status || apiErrorCode(w) != tc.code {
+			t.Errorf("%s: got %d %s", tc.payload, w.Code, w.Body.String())
+		}
+	}
+
+	restoreLists()
+}
+
+// Test creating, updating and deleting a product through the API
+func TestAPIProductLifecycle(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	r := getAPIRouter()
+	admin := getSessionCookie(t)
+
+	w := serveAPIRequest(r, "POST", "/api/v1/products", `{"name":"Mouse","price":5.5,"quantity":4}`, admin)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/api/v1/products/3" {
+		t.Errorf("create: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPIRequest(r, "PUT", "/api/v1/products/3", `{"name":"Mouse","price":-1,"quantity":4}`, admin)
+	if w.Code != http.StatusUnprocessableEntity || apiErrorCode(w) != middleware.ErrCodeValidation {
+		t.Errorf("invalid update: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPIRequest(r, "PUT", "/api/v1/products/3", `{"name":"Mouse","price":6,"quantity":4}`, admin)
+	if p, err := handlers.Products.ByID(context.Background(), 3); w.Code != http.StatusOK || err != nil || p.Price != 6 {
+		t.Errorf("update: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPIRequest(r, "DELETE", "/api/v1/products/3", "", admin)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("delete: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPIRequest(r, "GET", "/api/v1/products/3", "", nil)
+	if w.Code != http.StatusNotFound || apiErrorCode(w) != middleware.ErrCodeNotFound {
+		t.Errorf("get deleted: got %d %s", w.Code, w.Body.String())
+	}
+
+	restoreLists()
+}
+
+// Test registering, logging in and fetching the current user through the API
+func TestAPIUserAuth(t *testing.T) {
+	saveLists()
+	r := getAPIRouter()
+
+	for payload, status := range map[string]int{
+		`{"username":"apiuser","password":"apipass"}`: http.StatusCreated,
+		`{"username":"user1","password":"pass"}`:      http.StatusConflict,
+		`{"username":"emptypass","password":""}`:      http.StatusUnprocessableEntity,
+	} {
+		if w := serveAPIRequest(r, "POST", "/api/v1/users", payload, nil); w.Code != status {
+			t.Errorf("%s: got %d %s", payload, w.Code, w.Body.String())
+		}
+	}
+
+	w := serveAPIRequest(r, "POST", "/api/v1/auth/login", `{"username":"apiuser","password":"wrong"}`, nil)
+	if w.Code != http.StatusUnauthorized || apiErrorCode(w) != middleware.ErrCodeUnauthorized {
+		t.Errorf("wrong password: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPIRequest(r, "POST", "/api/v1/auth/login", `{"username":"apiuser","password":"apipass"}`, nil)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "token" {
+			cookie = c
+		}
+	}
+	if w.Code != http.StatusOK || cookie == nil {
+		t.Fatalf("login: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPIRequest(r, "GET", "/api/v1/users/me", "", cookie)
+	var body struct{ Data models.User }
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil ||
+		body.Data.Username != "apiuser" || body.Data.Role != models.RoleCustomer ||
+		strings.Contains(w.Body.String(), "password") {
+		t.Errorf("me: got %d %s", w.Code, w.Body.String())
+	}
+
+	if w = serveAPIRequest(r, "POST", "/api/v1/auth/logout", "", cookie); w.Code != http.StatusNoContent {
+		t.Errorf("logout: got %d", w.Code)
+	}
+	if w = serveAPIRequest(r, "GET", "/api/v1/users/me", "", cookie); w.Code != http.StatusUnauthorized {
+		t.Errorf("me after logout: got %d", w.Code)
+	}
+
+	restoreLists()
+}
+
+// Test issuing, using, refreshing and revoking bearer tokens
+func TestAPIBearerToken(t *testing.T) {
+	r := getAPIRouter()
+
+	for payload, status := range map[string]int{
+		`{"grant_type":"password","username":"user3","password":"wrong"}`: http.StatusUnauthorized,
+		`{"grant_type":"client_credentials"}`:                             http.StatusBadRequest,
+		`{"grant_type":"refresh_token","refresh_token":"unknown"}`:        http.StatusUnauthorized,
+	} {
+		if w := serveAPIRequest(r, "POST", "/api/v1/auth/token", payload, nil); w.Code != status {
+			t.Errorf("%s: got %d %s", payload, w.Code, w.Body.String())
+		}
+	}
+
+	first := requestAPIToken(t, r, `{"grant_type":"password","username":"user3","password":"pass3"}`)
+	if w := serveBearerRequest(r, "GET", "/api/v1/users/me", first.AccessToken); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), `"username":"user3"`) {
+		t.Errorf("me: got %d %s", w.Code, w.Body.String())
+	}
+	if w := serveBearerRequest(r, "GET", "/api/v1/users/me", first.AccessToken+"x"); w.Code != http.StatusUnauthorized {
+		t.Errorf("tampered token: got %d", w.Code)
+	}
+
+	// A refresh token can only be exchanged once
+	second := requestAPIToken(t, r, `{"grant_type":"refresh_token","refresh_token":"`+first.RefreshToken+`"}`)
+	w := serveAPIRequest(r, "POST", "/api/v1/auth/token",
+		`{"grant_type":"refresh_token","refresh_token":"`+first.RefreshToken+`"}`, nil)
+	if w.Code != http.StatusUnauthorized || apiErrorCode(w) != middleware.ErrCodeUnauthorized {
+		t.Errorf("reused refresh token: got %d %s", w.Code, w.Body.String())
+	}
+
+	// Revoking the refresh token revokes the access tokens issued for it
+	if w := serveAPIRequest(r, "POST", "/api/v1/auth/revoke",
+		`{"refresh_token":"`+second.RefreshToken+`"}`, nil); w.Code != http.StatusNoContent {
+		t.Errorf("revoke: got %d", w.Code)
+	}
+	for _, token := range []string{first.AccessToken, second.AccessToken} {
+		if w := serveBearerRequest(r, "GET", "/api/v1/users/me", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("revoked token: got %d", w.Code)
+		}
+	}
+}
+
+// Test that logging out revokes the refresh token of the bearer token the
+// request was authenticated with, and the one POSTed along
+func TestAPILogoutRevokesTokens(t *testing.T) {
+	r := getAPIRouter()
+
+	bearer := requestAPIToken(t, r, `{"grant_type":"password","username":"user3","password":"pass3"}`)
+	if w := serveBearerRequest(r, "POST", "/api/v1/auth/logout", bearer.AccessToken); w.Code != http.StatusNoContent {
+		t.Errorf("bearer logout: got %d %s", w.Code, w.Body.String())
+	}
+	if w := serveBearerRequest(r, "GET", "/api/v1/users/me", bearer.AccessToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: got %d", w.Code)
+	}
+	w := serveAPIRequest(r, "POST", "/api/v1/auth/token",
+		`{"grant_type":"refresh_token","refresh_token":"`+bearer.RefreshToken+`"}`, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token after bearer logout: got %d %s", w.Code, w.Body.String())
+	}
+
+	// A client using both a session and a refresh token hands the latter over
+	tokens := requestAPIToken(t, r, `{"grant_type":"password","username":"user3","password":"pass3"}`)
+	cookie := getSessionCookieFor(t, "user3")
+	w = serveAPIRequest(r, "POST", "/api/v1/auth/logout", `{"refresh_token":"`+tokens.RefreshToken+`"}`, cookie)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("session logout: got %d %s", w.Code, w.Body.String())
+	}
+	w = serveAPIRequest(r, "POST", "/api/v1/auth/token",
+		`{"grant_type":"refresh_token","refresh_token":"`+tokens.RefreshToken+`"}`, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token after session logout: got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// Test that expired access tokens are rejected
+func TestExpiredAccessToken(t *testing.T) {
+	issuer := models.NewTokenIssuer([]byte("secret"), models.NewMemoryRefreshTokenStore())
+	issuer.AccessTTL = -time.Minute
+
+	tokens, err := issuer.Issue(context.Background(), "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Verify(context.Background(), tokens.AccessToken); err != models.ErrTokenExpired {
+		t.Fail()
+	}
+
+	// Tokens signed with another secret aren't accepted either
+	other := models.NewTokenIssuer([]byte("other secret"), models.NewMemoryRefreshTokenStore())
+	if _, err := other.Verify(context.Background(), tokens.AccessToken); err != models.ErrInvalidToken {
+		t.Fail()
+	}
+}
+
+// Test that the Postgres store deletes the expired refresh tokens when a
+// token is issued
+func TestPostgresRefreshTokenPurge(t *testing.T) {
+	db, standIn := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return &standInResult{rowsAffected: 1}, nil
+	})
+	if _, err := models.NewPostgresRefreshTokenStore(db).Create(context.Background(), "user1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := standIn.received()
+	if len(queries) != 1 || !strings.Contains(queries[0], "delete from refresh_tokens where expires_at <= now()") ||
+		!strings.Contains(queries[0], "insert into refresh_tokens") {
+		t.Errorf("the expired tokens aren't deleted by %q", queries)
+	}
+}
+
+// Test creating, using, listing and revoking an API key
+func TestAPIKeys(t *testing.T) {
+	saveLists()
+	r := getAPIRouter()
+	editor := getSessionCookieFor(t, "user2")
+
+	w := serveAPIRequest(r, "POST", "/api/v1/keys", `{"name":"CI","scopes":["unknown:scope"]}`, editor)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid scope: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPIRequest(r, "POST", "/api/v1/keys", `{"name":"CI","scopes":["write:articles"]}`, editor)
+	var created struct{ Data models.APIKey }
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil ||
+		!strings.HasPrefix(created.Data.Key, created.Data.Prefix) || created.Data.LastUsedAt != nil {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+	key := created.Data.Key
+
+	for _, tc := range []struct {
+		method, url, payload, key string
+		status                    int
+	}{
+		// The key acts on behalf of its owner within its scopes
+		{"POST", "/api/v1/articles", `{"title":"From CI","content":"Body"}`, key, http.StatusCreated},
+		{"GET", "/api/v1/users/me", "", key, http.StatusForbidden},
+		{"GET", "/api/v1/keys", "", key, http.StatusForbidden},
+		{"GET", "/api/v1/articles", "", "gsk_unknown", http.StatusUnauthorized},
+	} {
+		w := serveAPIKeyRequest(r, tc.method, tc.url, tc.payload, tc.key)
+		if w.Code != tc.status {
+			t.Errorf("%s %s: got %d %s", tc.method, tc.url, w.Code, w.Body.String())
+		}
+	}
+
+	// The listed keys don't hold the plain key but record their last use
+	w = serveAPIRequest(r, "GET", "/api/v1/keys", "", editor)
+	var listed struct{ Data []models.APIKey }
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &listed) != nil || len(listed.Data) != 1 ||
+		listed.Data[0].Key != "" || listed.Data[0].LastUsedAt == nil || strings.Contains(w.Body.String(), key) {
+		t.Errorf("list: got %d %s", w.Code, w.Body.String())
+	}
+
+	// Other users can't revoke the key
+	url := "/api/v1/keys/" + strconv.Itoa(created.Data.ID)
+	if w := serveAPIRequest(r, "DELETE", url, "", getSessionCookieFor(t, "user3")); w.Code != http.StatusNotFound {
+		t.Errorf("revoke by another user: got %d", w.Code)
+	}
+	if w := serveAPIRequest(r, "DELETE", url, "", editor); w.Code != http.StatusNoContent {
+		t.Errorf("revoke: got %d", w.Code)
+	}
+	if w := serveAPIKeyRequest(r, "GET", "/api/v1/articles", "", key); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: got %d", w.Code)
+	}
+
+	restoreLists()
+}
+
+// Helper function to send a JSON request to the API with an API key
+func serveAPIKeyRequest(r *gin.Engine, method, url, payload, key string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-API-Key", key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// Helper function to request a pair of tokens, failing the test if they
+// aren't issued
+func requestAPIToken(t *testing.T, r *gin.Engine, payload string) models.TokenPair {
+	w := serveAPIRequest(r, "POST", "/api/v1/auth/token", payload, nil)
+	var body struct{ Data models.TokenPair }
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil ||
+		body.Data.TokenType != "Bearer" || body.Data.AccessToken == "" || body.Data.RefreshToken == "" {
+		t.Fatalf("token: got %d %s", w.Code, w.Body.String())
+	}
+	return body.Data
+}
+
+// Helper function to send a request to the API with a bearer token
+func serveBearerRequest(r *gin.Engine, method, url, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// Helper function to create a router serving the API routes like the
+// Test that the API requests rejected before reaching a handler still get
+// a JSON error body
+func TestAPIFallbacks(t *testing.T) {
+	saveLists()
+	defer restoreLists()
+	r := getRouter(false)
+	routes.InitializeRoutes(r)
+
+	for _, test := range []struct {
+		method, url, contentType string
+		expectedHTTPCode         int
+		expectedCode             string
+	}{
+		{"GET", "/api/v1/unknown", "application/json", http.StatusNotFound, middleware.ErrCodeNotFound},
+		{"PATCH", "/api/v1/articles", "application/json", http.StatusMethodNotAllowed, middleware.ErrCodeNotAllowed},
+		// A form sent without the CSRF token
+		{"POST", "/api/v1/articles", "application/x-www-form-urlencoded", http.StatusForbidden, middleware.ErrCodeForbidden},
+	} {
+		req, _ := http.NewRequest(test.method, test.url, strings.NewReader("title=a"))
+		req.Header.Add("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != test.expectedHTTPCode || apiErrorCode(w) != test.expectedCode {
+			t.Errorf("%s %s: unexpected response %d %s", test.method, test.url, w.Code, w.Body.String())
+		}
+	}
+
+	// The other requests keep the default answer
+	req, _ := http.NewRequest("GET", "/unknown", nil)
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusNotFound && apiErrorCode(w) == ""
+	})
+}
+
+// routes file does
+func getAPIRouter() *gin.Engine {
+	r := getRouter(true)
+	api := r.Group("/api/v1", middleware.API(), middleware.SetAPIKeyUser(handlers.APIKeys, handlers.Users))
+	api.GET("/articles", middleware.RequireScope(models.ScopeReadArticles), handlers.APIGetArticles)
+	api.GET("/articles/:article_id", middleware.RequireScope(models.ScopeReadArticles), handlers.APIGetArticle)
+	api.POST("/articles", middleware.RequireRole(models.RoleEditor, models.RoleAdmin),
+		middleware.RequireScope(models.ScopeWriteArticles), handlers.APICreateArticle)
+	api.GET("/products", middleware.RequireScope(models.ScopeReadProducts), handlers.APIGetProducts)
+	api.GET("/products/:product_id", middleware.RequireScope(models.ScopeReadProducts), handlers.APIGetProduct)
+	api.POST("/products", middleware.RequireRole(models.RoleAdmin),
+		middleware.RequireScope(models.ScopeWriteProducts), handlers.APICreateProduct)
+	api.PUT("/products/:product_id", middleware.RequireRole(models.RoleAdmin),
+		middleware.RequireScope(models.ScopeWriteProducts), handlers.APIUpdateProduct)
+	api.DELETE("/products/:product_id", middleware.RequireRole(models.RoleAdmin),
+		middleware.RequireScope(models.ScopeWriteProducts), handlers.APIDeleteProduct)
+	api.POST("/users", middleware.EnsureNotLoggedIn(), handlers.APIRegister)
+	api.GET("/users/me", middleware.EnsureLoggedIn(), middleware.RequireScope(models.ScopeReadProfile),
+		handlers.APIGetCurrentUser)
+	api.POST("/auth/login", middleware.EnsureNotLoggedIn(), handlers.APILogin)
+	api.POST("/auth/logout", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APILogout)
+	api.POST("/auth/token", handlers.APIToken)
+	api.POST("/auth/revoke", handlers.APIRevokeToken)
+	api.GET("/keys", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APIGetKeys)
+	api.POST("/keys", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APICreateKey)
+	api.DELETE("/keys/:key_id", middleware.EnsureLoggedIn(), middleware.EnsureNotAPIKey(), handlers.APIDeleteKey)
+	return r
+}
+
+// Helper function to send a JSON request to the API, optionally with a
+// session cookie
+func serveAPIRequest(r *gin.Engine, method, url, payload string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// Helper function returning the code of an API error response
+func apiErrorCode(w *httptest.ResponseRecorder) string {
+	var body middleware.APIError
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return body.Error.Code
+}
@@ -4,6 +4,8 @@ import (
 	"GolangStore/handlers"
 	"GolangStore/middleware"
 	"GolangStore/models"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
 	"io/ioutil"
@@ -12,25 +14,27 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 )
 
 /* =============================== MODELS TESTS =============================== */
 // Test the function that fetches all articles
 func TestGetAllArticles(t *testing.T) {
-	alist := models.GetAllArticles()
+	repo := models.NewMemoryArticleRepository(models.DemoArticles...)
+	alist, err := repo.All(context.Background())
 
 	// Check that the length of the list of articles returned is the
-	// same as the length of the global variable holding the list
-	if len(alist) != len(models.ArticleList) {
+	// same as the length of the list the repository was created with
+	if err != nil || len(alist) != len(models.DemoArticles) {
 		t.Fail()
 	}
 
 	// Check that each member is identical
 	for i, v := range alist {
-		if v.Content != models.ArticleList[i].Content ||
-			v.ID != models.ArticleList[i].ID ||
-			v.Title != models.ArticleList[i].Title {
+		if v.Content != models.DemoArticles[i].Content ||
+			v.ID != models.DemoArticles[i].ID ||
+			v.Title != models.DemoArticles[i].Title {
 
 			t.Fail()
 			break
@@ -40,32 +44,65 @@ func TestGetAllArticles(t *testing.T) {
 
 // Test the function that fetche an Article by its ID
 func TestGetArticleByID(t *testing.T) {
-	a, err := models.GetArticleByID(1)
+	repo := models.NewMemoryArticleRepository(models.DemoArticles...)
+	a, err := repo.ByID(context.Background(), 1)
 
 	if err != nil || a.ID != 1 || a.Title != "Article 1" || a.Content != "Article 1 body" {
 		t.Fail()
 	}
+
+	if _, err := repo.ByID(context.Background(), 42); err != models.ErrArticleNotFound {
+		t.Fail()
+	}
 }
 
 // Test the function that creates a new article
 func TestCreateNewArticle(t *testing.T) {
+	repo := models.NewMemoryArticleRepository(models.DemoArticles...)
+
 	// get the original count of articles
-	originalLength := len(models.GetAllArticles())
+	originalArticles, _ := repo.All(context.Background())
+	originalLength := len(originalArticles)
 
 	// add another article
-	a, err := models.CreateNewArticle("New test title", "New test content")
+	a := &models.Article{Title: "New test title", Content: "New test content"}
+	err := repo.Create(context.Background(), a)
 
 	// get the new count of articles
-	allArticles := models.GetAllArticles()
+	allArticles, _ := repo.All(context.Background())
 	newLength := len(allArticles)
 
-	if err != nil || newLength != originalLength+1 ||
+	if err != nil || newLength != originalLength+1 || a.ID != originalLength+1 ||
 		a.Title != "New test title" || a.Content != "New test content" {
 
 		t.Fail()
 	}
 }
 
+// Test that articles created at the same time all get a distinct ID
+func TestCreateArticlesConcurrently(t *testing.T) {
+	repo := models.NewMemoryArticleRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repo.Create(context.Background(), &models.Article{Title: "Title", Content: "Content"})
+		}()
+	}
+	wg.Wait()
+
+	articles, _ := repo.All(context.Background())
+	seen := map[int]bool{}
+	for _, a := range articles {
+		seen[a.ID] = true
+	}
+	if len(articles) != 50 || len(seen) != 50 {
+		t.Fail()
+	}
+}
+
 /* =============================== HANDLERS TESTS =============================== */
 // Test that a GET request to the home page returns the home page with
 // the HTTP code 200 for an unauthenticated user
@@ -101,7 +138,7 @@ func TestShowIndexPageAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
 	r.GET("/", handlers.ShowIndexPage)
@@ -164,7 +201,7 @@ func TestArticleAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
 	r.GET("/article/view/:article_id", handlers.GetArticle)
@@ -222,6 +259,45 @@ func TestArticleListJSON(t *testing.T) {
 	})
 }
 
+// Test that a GET request to the home page returns the list of articles
+// in CSV format when the Accept header is set to text/csv
+func TestArticleListCSV(t *testing.T) {
+	saveLists()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/", handlers.ShowIndexPage)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Add("Accept", "text/csv")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		records, err := csv.NewReader(w.Body).ReadAll()
+
+		return w.Code == http.StatusOK && err == nil && len(records) == 3 &&
+			strings.Join(records[0], ",") == "id,title,content" && records[1][1] == "Article 1"
+	})
+
+	restoreLists()
+}
+
+// Test that a single article can't be returned in CSV format
+func TestArticleCSVNotAcceptable(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/article/view/:article_id", handlers.GetArticle)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/article/view/1", nil)
+	req.Header.Add("Accept", "text/csv")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusNotAcceptable
+	})
+}
+
 // Test that a GET request to an article page returns the article in XML
 // format when the Accept header is set to application/xml
 func TestArticleXML(t *testing.T) {
@@ -261,10 +337,10 @@ func TestArticleCreationPageAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
-	r.GET("/article/create", middleware.EnsureLoggedIn(), handlers.ShowArticleCreationPage)
+	r.GET("/article/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.ShowArticleCreationPage)
 
 	// Create a request to send to the above route
 	res := w.Result()
@@ -296,7 +372,7 @@ func TestArticleCreationPageUnauthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Define the route similar to its definition in the routes file
-	r.GET("/article/create", middleware.EnsureLoggedIn(), handlers.ShowArticleCreationPage)
+	r.GET("/article/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.ShowArticleCreationPage)
 
 	// Create a request to send to the above route
 	req, _ := http.NewRequest("GET", "/article/create", nil)
@@ -317,10 +393,10 @@ func TestArticleCreationAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
-	r.POST("/article/create", middleware.EnsureLoggedIn(), handlers.CreateArticle)
+	r.POST("/article/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.CreateArticle)
 
 	// Create a request to send to the above route
 	articlePayload := getArticlePOSTPayload()
@@ -355,7 +431,7 @@ func TestArticleCreationUnauthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Define the route similar to its definition in the routes file
-	r.POST("/article/create", middleware.EnsureLoggedIn(), handlers.CreateArticle)
+	r.POST("/article/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.CreateArticle)
 
 	// Create a request to send to the above route
 	articlePayload := getArticlePOSTPayload()
@@ -369,6 +445,56 @@ func TestArticleCreationUnauthenticated(t *testing.T) {
 	})
 }
 
+// Test that a POST request to create an article returns
+// an HTTP 403 error for a customer
+func TestArticleCreationCustomer(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/article/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.CreateArticle)
+
+	// Create a request to send to the above route as user3, a customer
+	articlePayload := getArticlePOSTPayload()
+	req, _ := http.NewRequest("POST", "/article/create", strings.NewReader(articlePayload))
+	req.AddCookie(getSessionCookieFor(t, "user3"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(articlePayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		// Test that the http status code is 403
+		return w.Code == http.StatusForbidden
+	})
+}
+
+// Test that a POST request with an empty article shows the form again with
+// an HTTP 400 error, and doesn't store anything
+func TestArticleCreationInvalid(t *testing.T) {
+	saveLists()
+	defer restoreLists()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/article/create", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), handlers.CreateArticle)
+
+	// Create a request without title nor content
+	articlePayload := url.Values{"title": {" "}, "content": {""}}.Encode()
+	req, _ := http.NewRequest("POST", "/article/create", strings.NewReader(articlePayload))
+	req.AddCookie(getSessionCookie(t))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(articlePayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		p, err := ioutil.ReadAll(w.Body)
+		pageOK := err == nil && strings.Contains(string(p), "the article title can&#39;t be empty")
+
+		return w.Code == http.StatusBadRequest && pageOK
+	})
+
+	if articles, _ := handlers.Articles.All(context.Background()); len(articles) != len(models.DemoArticles) {
+		t.Errorf("the empty article was stored: %+v", articles)
+	}
+}
+
 func getArticlePOSTPayload() string {
 	params := url.Values{}
 	params.Add("title", "Test Article Title")
@@ -1,10 +1,16 @@
 package tests
 
 import (
+	"GolangStore/handlers"
 	"GolangStore/middleware"
+	"GolangStore/models"
+	"context"
+	"database/sql/driver"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -70,11 +76,12 @@ func TestEnsureNotLoggedInUnauthenticated(t *testing.T) {
 // Test the setUserStatus middleware when the user is logged in
 func TestSetUserStatusAuthenticated(t *testing.T) {
 	r := getRouter(false)
-	r.GET("/", middleware.SetUserStatus(), func(c *gin.Context) {
+	r.GET("/", middleware.SetUserStatus(handlers.Sessions, handlers.Tokens, handlers.Users), func(c *gin.Context) {
 		// as the token cookie was set, the "is_logged_in" should have been set
 		// to true by the setUserStatus middleware
 		loggedInInterface, exists := c.Get("is_logged_in")
-		if !exists || !loggedInInterface.(bool) {
+		if !exists || !loggedInInterface.(bool) || c.GetString("username") != "user1" ||
+			c.GetString("role") != models.RoleAdmin {
 			t.Fail()
 		}
 	})
@@ -83,7 +90,7 @@ func TestSetUserStatusAuthenticated(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Set the cookie
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	res := w.Result()
 	defer res.Body.Close()
@@ -98,7 +105,7 @@ func TestSetUserStatusAuthenticated(t *testing.T) {
 // Test the setUserStatus middleware when the user is not logged in
 func TestSetUserStatusUnauthenticated(t *testing.T) {
 	r := getRouter(false)
-	r.GET("/", middleware.SetUserStatus(), func(c *gin.Context) {
+	r.GET("/", middleware.SetUserStatus(handlers.Sessions, handlers.Tokens, handlers.Users), func(c *gin.Context) {
 		// as the token cookie was not set, the "is_logged_in" should have been set
 		// to false by the setUserStatus middleware
 		loggedInInterface, exists := c.Get("is_logged_in")
@@ -117,6 +124,100 @@ func TestSetUserStatusUnauthenticated(t *testing.T) {
 	r.ServeHTTP(w, req)
 }
 
+// Test the setUserStatus middleware when the token doesn't belong to a session
+func TestSetUserStatusUnknownToken(t *testing.T) {
+	r := getRouter(false)
+	r.GET("/", middleware.SetUserStatus(handlers.Sessions, handlers.Tokens, handlers.Users), func(c *gin.Context) {
+		// as the token isn't known by the session store, the "is_logged_in"
+		// should have been set to false by the setUserStatus middleware
+		if c.GetBool("is_logged_in") {
+			t.Fail()
+		}
+	})
+
+	// Create a request to send to the above route with a made up token
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: "123"})
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusOK
+	})
+}
+
+// Test the requireRole middleware with users of every role
+func TestRequireRole(t *testing.T) {
+	r := getRouter(true)
+	r.GET("/", middleware.RequireRole(models.RoleEditor, models.RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for username, expectedHTTPCode := range map[string]int{
+		"user1": http.StatusOK,        // administrator
+		"user2": http.StatusOK,        // editor
+		"user3": http.StatusForbidden, // customer
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.AddCookie(getSessionCookieFor(t, username))
+
+		testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+			return w.Code == expectedHTTPCode
+		})
+	}
+
+	// Users that aren't logged in are unauthorized
+	testMiddlewareRequest(t, r, http.StatusUnauthorized)
+}
+
+// Test that a newly registered user is a customer
+func TestRegisteredUserRole(t *testing.T) {
+	users := models.NewMemoryUserRepository()
+
+	if u, err := models.RegisterNewUser(context.Background(), users, "newuser", "newpass"); err != nil || u.Role != models.RoleCustomer {
+		t.Fail()
+	}
+}
+
+// Test the session stores with an expired session
+func TestExpiredSession(t *testing.T) {
+	sessions := models.NewMemorySessionStore()
+	session, err := sessions.Create(context.Background(), "user1", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sessions.Get(context.Background(), session.Token); err != models.ErrSessionNotFound {
+		t.Fail()
+	}
+}
+
+// Test that the Postgres store deletes the expired sessions when a session
+// starts
+func TestPostgresSessionPurge(t *testing.T) {
+	db, standIn := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return &standInResult{rowsAffected: 1}, nil
+	})
+	if _, err := models.NewPostgresSessionStore(db).Create(context.Background(), "user1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := standIn.received()
+	if len(queries) != 1 || !strings.Contains(queries[0], "delete from sessions where expires_at <= now()") ||
+		!strings.Contains(queries[0], "insert into sessions") {
+		t.Errorf("the expired sessions aren't deleted by %q", queries)
+	}
+}
+
+// Test that two sessions never share the same token
+func TestSessionTokensAreUnique(t *testing.T) {
+	sessions := models.NewMemorySessionStore()
+	first, _ := sessions.Create(context.Background(), "user1", time.Hour)
+	second, _ := sessions.Create(context.Background(), "user1", time.Hour)
+
+	if first.Token == second.Token || len(first.Token) != 64 {
+		t.Fail()
+	}
+}
+
 // This is a middleware that will set the value of "is_logged_in" to
 // true or false depending on the value passed in. This is used only for testing
 func setLoggedIn(b bool) gin.HandlerFunc {
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/* =============================== MODELS TESTS =============================== */
+// Test that products can't be put in a cart beyond their stock
+func TestAddToCartStock(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	carts := models.NewMemoryCartRepository()
+	owner := models.UserCartOwner("user1")
+
+	// The keyboard has 3 units in stock
+	if err := models.AddToCart(context.Background(), carts, products, owner, 1, 2); err != nil {
+		t.Fail()
+	}
+	if err := models.AddToCart(context.Background(), carts, products, owner, 1, 2); err != models.ErrInsufficientStock {
+		t.Fail()
+	}
+	if err := models.AddToCart(context.Background(), carts, products, owner, 1, 1); err != nil {
+		t.Fail()
+	}
+	if err := models.AddToCart(context.Background(), carts, products, owner, 42, 1); err != models.ErrProductNotFound {
+		t.Fail()
+	}
+	if err := models.AddToCart(context.Background(), carts, products, owner, 2, 0); err != models.ErrInvalidQuantity {
+		t.Fail()
+	}
+
+	cart, err := models.LoadCart(context.Background(), carts, products, owner)
+	if err != nil || len(cart.Items) != 1 || cart.Items[0].Quantity != 3 || cart.Total != 76.5 {
+		t.Fail()
+	}
+}
+
+// Test that setting the quantity of a product to zero takes it out of the cart
+func TestUpdateCartQuantity(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	carts := models.NewMemoryCartRepository()
+	owner := models.UserCartOwner("user1")
+
+	models.AddToCart(context.Background(), carts, products, owner, 1, 1)
+	models.AddToCart(context.Background(), carts, products, owner, 2, 1)
+
+	if err := models.UpdateCartQuantity(context.Background(), carts, products, owner, 2, 3); err != models.ErrInsufficientStock {
+		t.Fail()
+	}
+	if err := models.UpdateCartQuantity(context.Background(), carts, products, owner, 1, 0); err != nil {
+		t.Fail()
+	}
+
+	entries, _ := carts.Entries(context.Background(), owner)
+	if len(entries) != 1 || entries[0].ProductID != 2 || entries[0].Quantity != 1 {
+		t.Fail()
+	}
+}
+
+// Test that merging carts adds up the quantities and empties the source cart
+func TestMergeCarts(t *testing.T) {
+	carts := models.NewMemoryCartRepository()
+	anonymous := models.AnonymousCartOwner("abc")
+	user := models.UserCartOwner("user1")
+
+	carts.SetQuantity(context.Background(), anonymous, 1, 1)
+	carts.SetQuantity(context.Background(), anonymous, 2, 2)
+	carts.SetQuantity(context.Background(), user, 1, 1)
+
+	if err := carts.Merge(context.Background(), anonymous, user); err != nil {
+		t.Fail()
+	}
+
+	entries, _ := carts.Entries(context.Background(), user)
+	if len(entries) != 2 || entries[0].Quantity != 2 || entries[1].Quantity != 2 {
+		t.Fail()
+	}
+	if entries, _ := carts.Entries(context.Background(), anonymous); len(entries) != 0 {
+		t.Fail()
+	}
+}
+
+/* =============================== HANDLERS TESTS =============================== */
+// Test that an anonymous visitor gets a cart cookie when adding a product
+// and that the cart is returned in JSON format
+func TestAddToCartUnauthenticated(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/cart/add", handlers.AddToCart)
+
+	// Create a request to send to the above route
+	req := getCartPOSTRequest("/cart/add", "2", "1")
+	req.Header.Add("Accept", "application/json")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		var cart models.Cart
+		err := json.Unmarshal(w.Body.Bytes(), &cart)
+
+		return w.Code == http.StatusOK && err == nil && len(cart.Items) == 1 &&
+			cart.Items[0].Product.Name == "Monitor" && getCartCookie(w) != nil
+	})
+}
+
+// Test that adding more units than available is rejected with an HTTP 400 error
+func TestAddToCartInsufficientStock(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/cart/add", handlers.AddToCart)
+
+	// Create a request to send to the above route
+	req := getCartPOSTRequest("/cart/add", "2", "5")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusBadRequest &&
+			strings.Contains(w.Body.String(), "Unable to update the cart")
+	})
+}
+
+// Test that the cart filled before logging in is kept after logging in
+func TestLoginMergesCart(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	handlers.Carts = models.NewMemoryCartRepository()
+	r := getRouter(true)
+
+	// Define the routes similar to their definition in the routes file
+	r.POST("/cart/add", handlers.AddToCart)
+	r.POST("/u/login", middleware.EnsureNotLoggedIn(), handlers.PerformLogin)
+
+	// Fill the cart as an anonymous visitor
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, getCartPOSTRequest("/cart/add", "1", "2"))
+	cookie := getCartCookie(w)
+	if cookie == nil {
+		t.Fatal("the cart cookie wasn't set")
+	}
+
+	// Log in with the cart cookie
+	loginPayload := getLoginPOSTPayload()
+	req, _ := http.NewRequest("POST", "/u/login", strings.NewReader(loginPayload))
+	req.AddCookie(cookie)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(loginPayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusOK
+	})
+
+	entries, _ := handlers.Carts.Entries(context.Background(), models.UserCartOwner("user1"))
+	if len(entries) != 1 || entries[0].ProductID != 1 || entries[0].Quantity != 2 {
+		t.Fail()
+	}
+
+	restoreLists()
+}
+
+// Helper function to build a POST request changing the cart
+func getCartPOSTRequest(path, productID, quantity string) *http.Request {
+	params := url.Values{}
+	params.Add("product_id", productID)
+	params.Add("quantity", quantity)
+	payload := params.Encode()
+
+	req, _ := http.NewRequest("POST", path, strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(payload)))
+	return req
+}
+
+// Helper function to find the cart cookie set by a response
+func getCartCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "cart" && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
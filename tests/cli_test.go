@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"GolangStore/cli"
+	"GolangStore/config"
+	"GolangStore/models"
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// Test that the products are created or updated depending on their ID
+func TestImportProducts(t *testing.T) {
+	products := models.NewMemoryProductRepository(models.Product{Id: 1, Name: "Old name", Price: 5, Quantity: 1})
+
+	created, updated, err := cli.ImportProducts(context.Background(), products, strings.NewReader(
+		"id,name,description,price,quantity\n"+
+			"1,New name,,7.5,3\n"+
+			",Lamp,\"Bright, white\",20,10\n"), "csv")
+	if err != nil || created != 1 || updated != 1 {
+		t.Fatalf("expected 1 product created and 1 updated, got %d, %d and %v", created, updated, err)
+	}
+
+	p, err := products.ByID(context.Background(), 1)
+	if err != nil || p.Name != "New name" || p.Price != 7.5 || p.Quantity != 3 {
+		t.Errorf("the product wasn't updated: %+v", p)
+	}
+	p, err = products.ByID(context.Background(), 2)
+	if err != nil || p.Name != "Lamp" || p.Description != "Bright, white" {
+		t.Errorf("the product wasn't created: %+v", p)
+	}
+}
+
+// Test that nothing is imported when a product is invalid
+func TestImportInvalidProducts(t *testing.T) {
+	products := models.NewMemoryProductRepository()
+
+	_, _, err := cli.ImportProducts(context.Background(), products, strings.NewReader(
+		`[{"name": "Lamp", "price": 20, "quantity": 10}, {"name": "Chair", "price": -1}]`), "json")
+	if err == nil {
+		t.Error("the negative price should have been rejected")
+	}
+	if list, _ := products.All(context.Background()); len(list) != 0 {
+		t.Errorf("%d products were imported", len(list))
+	}
+
+	if _, _, err := cli.ImportProducts(context.Background(), products, strings.NewReader("name,colour\nLamp,white\n"), "csv"); err == nil {
+		t.Error("the unknown column should have been rejected")
+	}
+}
+
+// Test that a product whose ID doesn't exist isn't created under another ID,
+// which would duplicate it every time the file is imported
+func TestImportUnknownProductID(t *testing.T) {
+	products := models.NewMemoryProductRepository(models.Product{Id: 1, Name: "Lamp", Price: 20, Quantity: 10})
+
+	created, updated, err := cli.ImportProducts(context.Background(), products, strings.NewReader(
+		`[{"id": 1, "name": "Lamp", "price": 25, "quantity": 10}, {"id": 7, "name": "Chair", "price": 5, "quantity": 1}]`), "json")
+	if !errors.Is(err, models.ErrProductNotFound) || !strings.HasPrefix(err.Error(), "product 2:") ||
+		created != 0 || updated != 1 {
+		t.Errorf("expected the second product to be rejected, got %d created, %d updated and %v", created, updated, err)
+	}
+	if list, _ := products.All(context.Background()); len(list) != 1 {
+		t.Errorf("%d products are stored", len(list))
+	}
+}
+
+// Test that a failure of the repository tells which product it stopped at,
+// and that the products can be stored in a transaction
+func TestImportProductsFailure(t *testing.T) {
+	db, standIn := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		if len(args) > 0 && args[0].Value == "Chair" {
+			return nil, &pq.Error{Code: "23514", Message: "check constraint violated"}
+		}
+		return &standInResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}}, nil
+	})
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	created, _, err := cli.ImportProducts(context.Background(), models.NewPostgresProductRepository(tx), strings.NewReader(
+		`[{"name": "Lamp", "price": 20, "quantity": 10}, {"name": "Chair", "price": 5, "quantity": 1}]`), "json")
+	if err == nil || !strings.HasPrefix(err.Error(), "product 2:") || created != 1 {
+		t.Errorf("expected the second product to fail, got %d created and %v", created, err)
+	}
+	if len(standIn.received()) != 2 {
+		t.Errorf("unexpected queries %q", standIn.received())
+	}
+}
+
+// Test exporting the orders having a status as CSV and JSON
+func TestExportOrders(t *testing.T) {
+	products := models.NewMemoryProductRepository(models.Product{Id: 1, Name: "Lamp", Price: 20, Quantity: 10})
+	orders := models.NewMemoryOrderRepository(products)
+	for _, username := range []string{"user1", "user3"} {
+		if _, err := orders.Place(context.Background(), username, []models.CartEntry{{ProductID: 1, Quantity: 2}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := orders.UpdateStatus(context.Background(), 1, models.OrderCancelled, "user1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	count, err := cli.ExportOrders(context.Background(), orders, &out, "csv", models.OrderPending)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if err != nil || count != 1 || len(lines) != 2 || !strings.HasPrefix(lines[1], "2,user3,pending,40,") {
+		t.Errorf("unexpected CSV export (%d, %v):\n%s", count, err, out.String())
+	}
+
+	out.Reset()
+	var exported []models.Order
+	count, err = cli.ExportOrders(context.Background(), orders, &out, "json", "")
+	if err != nil || count != 2 || json.Unmarshal(out.Bytes(), &exported) != nil ||
+		len(exported) != 2 || len(exported[0].Items) != 1 {
+		t.Errorf("unexpected JSON export (%d, %v):\n%s", count, err, out.String())
+	}
+}
+
+// Test that the wrong command lines are rejected before connecting
+func TestRunUsage(t *testing.T) {
+	tmpStderr := cli.Stderr
+	cli.Stderr = ioutil.Discard
+	defer func() { cli.Stderr = tmpStderr }()
+
+	for _, args := range [][]string{
+		{"unknown"},
+		{"migrate"},
+		{"migrate", "sideways"},
+		{"create-user"},
+		{"create-user", "-role"},
+		{"export-orders", "-format", "xml"},
+		{"import-products", "a.csv", "b.csv"},
+	} {
+		if err := cli.Run(config.Default(), args); err != cli.ErrUsage {
+			t.Errorf("%v: expected a usage error, got %v", args, err)
+		}
+	}
+
+	if err := cli.Run(config.Default(), []string{"create-user", "-role", "boss", "someone"}); err != models.ErrInvalidRole {
+		t.Errorf("expected the role to be rejected, got %v", err)
+	}
+}
+
+// Test that the usage is printed only when it's asked for
+func TestHelp(t *testing.T) {
+	tmpStdout := cli.Stdout
+	defer func() { cli.Stdout = tmpStdout }()
+
+	for args, expected := range map[string]bool{"help": true, "-h": true, "--help": true, "migrate": false, "": false} {
+		var out bytes.Buffer
+		cli.Stdout = &out
+		if cli.Help(strings.Fields(args)) != expected || strings.Contains(out.String(), "Commands:") != expected {
+			t.Errorf("%q: expected the usage to be printed: %v, got %q", args, expected, out.String())
+		}
+	}
+}
@@ -1,18 +1,25 @@
 package tests
 
 import (
+	"GolangStore/handlers"
 	"GolangStore/middleware"
 	"GolangStore/models"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-var tmpUserList []models.User
-var tmpArticleList []models.Article
+var tmpUsers models.UserRepository
+var tmpArticles models.ArticleRepository
+var tmpProducts models.ProductRepository
+var tmpCarts models.CartRepository
+var tmpOrders models.OrderRepository
+var tmpPayments models.PaymentGateway
 
 // This function is used to do setup before executing the test functions
 func TestMain(m *testing.M) {
@@ -28,11 +35,27 @@ func getRouter(withTemplates bool) *gin.Engine {
 	r := gin.Default()
 	if withTemplates {
 		r.LoadHTMLGlob("../templates/*")
-		r.Use(middleware.SetUserStatus())
+		r.Use(middleware.SetUserStatus(handlers.Sessions, handlers.Tokens, handlers.Users))
 	}
 	return r
 }
 
+// Helper function to start a session for user1, an administrator, and
+// return the cookie holding its token, to simulate an authenticated user
+func getSessionCookie(t *testing.T) *http.Cookie {
+	return getSessionCookieFor(t, "user1")
+}
+
+// Helper function to start a session for the given user and return the
+// cookie holding its token
+func getSessionCookieFor(t *testing.T, username string) *http.Cookie {
+	session, err := handlers.Sessions.Create(context.Background(), username, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: "token", Value: session.Token}
+}
+
 // Helper function to process a request and test its response
 func testHTTPResponse(t *testing.T, r *gin.Engine, req *http.Request, f func(w *httptest.ResponseRecorder) bool) {
 
@@ -59,15 +82,25 @@ func testMiddlewareRequest(t *testing.T, r *gin.Engine, expectedHTTPCode int) {
 	})
 }
 
-// This function is used to store the main lists into the temporary one
-// for testing
+// This function is used to store the main repositories into the temporary
+// ones and replace them with fresh ones for testing
 func saveLists() {
-	tmpUserList = models.UserList
-	tmpArticleList = models.ArticleList
+	tmpUsers = handlers.Users
+	handlers.Users = models.NewMemoryUserRepository(models.DemoUsers()...)
+	tmpArticles = handlers.Articles
+	handlers.Articles = models.NewMemoryArticleRepository(models.DemoArticles...)
+	tmpProducts = handlers.Products
+	tmpCarts = handlers.Carts
+	tmpOrders = handlers.Orders
+	tmpPayments = handlers.Payments
 }
 
-// This function is used to restore the main lists from the temporary one
+// This function is used to restore the main repositories from the temporary ones
 func restoreLists() {
-	models.UserList = tmpUserList
-	models.ArticleList = tmpArticleList
+	handlers.Users = tmpUsers
+	handlers.Articles = tmpArticles
+	handlers.Products = tmpProducts
+	handlers.Carts = tmpCarts
+	handlers.Orders = tmpOrders
+	handlers.Payments = tmpPayments
 }
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"GolangStore/config"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test reading the settings from a file, overridden by the environment
+func TestLoadConfig(t *testing.T) {
+	path := writeConfigFile(t, `
+mode: release
+server:
+  address: "127.0.0.1:9000"
+database:
+  host: db.internal
+  user: store
+  password: "secret pass"
+  name: store
+  sslmode: require
+  max_open_conns: 40
+  conn_max_lifetime: 1h
+cookies:
+  secure: true
+  same_site: strict
+`)
+	t.Setenv("PG_HOST", "db.override")
+	t.Setenv("DB_MAX_IDLE_CONNS", "4")
+	t.Setenv("DB_QUERY_TIMEOUT", "2s")
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := cfg.Database
+	if cfg.Mode != "release" || cfg.Server.Address != "127.0.0.1:9000" || d.Host != "db.override" ||
+		d.MaxOpenConns != 40 || d.MaxIdleConns != 4 || d.ConnMaxLifetime != time.Hour ||
+		d.ConnMaxIdleTime != 5*time.Minute || d.QueryTimeout != 2*time.Second || !cfg.Cookies.Secure || cfg.Cookies.SameSite != "strict" {
+		t.Errorf("unexpected configuration: %+v", cfg)
+	}
+
+	expectedDSN := `host=db.override user=store password='secret pass' dbname=store sslmode=require`
+	if dsn := d.ConnectionString(); dsn != expectedDSN {
+		t.Errorf("unexpected connection string: %s", dsn)
+	}
+}
+
+// Test that the invalid settings are all reported
+func TestInvalidConfig(t *testing.T) {
+	path := writeConfigFile(t, `
+mode: production
+database:
+  sslmode: sometimes
+  max_open_conns: 5
+  max_idle_conns: 10
+  connect_attempts: 0
+  query_timeout: -1s
+cookies:
+  same_site: none
+payments:
+  fake_mode: sometimes
+  fake_timeout: -1s
+`)
+
+	_, err := config.Load(path)
+	if err == nil {
+		t.Fatal("the configuration should have been rejected")
+	}
+	for _, problem := range []string{"mode", "database.sslmode", "database.max_idle_conns",
+		"database.connect_attempts", "database.query_timeout", "cookies.same_site none",
+		"payments.fake_mode", "payments.fake_timeout"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("%q isn't reported in: %v", problem, err)
+		}
+	}
+}
+
+// Test that unknown keys and malformed environment variables are rejected
+func TestMalformedConfig(t *testing.T) {
+	if _, err := config.Load(writeConfigFile(t, "databse:\n  host: localhost\n")); err == nil {
+		t.Error("the misspelled key should have been rejected")
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	if _, err := config.Load(writeConfigFile(t, "")); err == nil || !strings.Contains(err.Error(), "DB_MAX_OPEN_CONNS") {
+		t.Errorf("the malformed variable should have been rejected: %v", err)
+	}
+}
+
+// Helper function to write a configuration file in a temporary directory
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
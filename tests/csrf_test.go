@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Test that the forms are only accepted along with the CSRF token
+func TestCSRFProtection(t *testing.T) {
+	r := getCSRFRouter()
+	cookie := getCSRFCookie(t, r)
+
+	for _, tc := range []struct {
+		name        string
+		path        string
+		contentType string
+		form        url.Values
+		header      http.Header
+		status      int
+	}{
+		{"missing token", "/form", "application/x-www-form-urlencoded", url.Values{}, nil, http.StatusForbidden},
+		{"wrong token", "/form", "application/x-www-form-urlencoded",
+			url.Values{"csrf_token": {strings.Repeat("0", 64)}}, nil, http.StatusForbidden},
+		{"form token", "/form", "application/x-www-form-urlencoded",
+			url.Values{"csrf_token": {cookie.Value}}, nil, http.StatusOK},
+		{"header token", "/form", "application/x-www-form-urlencoded", url.Values{},
+			http.Header{"X-Csrf-Token": {cookie.Value}}, http.StatusOK},
+		{"text body", "/form", "text/plain", url.Values{}, nil, http.StatusForbidden},
+		{"JSON body", "/form", "application/json", url.Values{}, nil, http.StatusOK},
+		{"bearer token", "/form", "application/x-www-form-urlencoded", url.Values{},
+			http.Header{"Authorization": {"Bearer token"}}, http.StatusOK},
+		{"exempt path", "/webhook", "application/x-www-form-urlencoded", url.Values{}, nil, http.StatusOK},
+	} {
+		req, _ := http.NewRequest("POST", tc.path, strings.NewReader(tc.form.Encode()))
+		for name, values := range tc.header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Content-Type", tc.contentType)
+		req.AddCookie(cookie)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s: got %d", tc.name, w.Code)
+		}
+	}
+}
+
+// Test that the pages put the CSRF token in their forms
+func TestCSRFTokenInForms(t *testing.T) {
+	r := getCSRFRouter()
+	r.GET("/u/login", handlers.ShowLoginPage)
+
+	req, _ := http.NewRequest("GET", "/u/login", nil)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		var token string
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "csrf" {
+				token = c.Value
+			}
+		}
+		return w.Code == http.StatusOK && token != "" &&
+			strings.Contains(w.Body.String(), `name="csrf_token" value="`+token+`"`)
+	})
+}
+
+// Test that logging in and out hands out a new CSRF token, so that one
+// captured before can't be used afterwards
+func TestCSRFTokenRotation(t *testing.T) {
+	saveLists()
+	defer restoreLists()
+
+	r := getCSRFRouter()
+	r.POST("/u/login", middleware.EnsureNotLoggedIn(), handlers.PerformLogin)
+	r.GET("/u/logout", middleware.EnsureLoggedIn(), handlers.Logout)
+	before := getCSRFCookie(t, r)
+
+	form := url.Values{"username": {"user1"}, "password": {"pass1"}, "csrf_token": {before.Value}}
+	req, _ := http.NewRequest("POST", "/u/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(before)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var session, afterLogin *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		switch c.Name {
+		case "token":
+			session = c
+		case "csrf":
+			afterLogin = c
+		}
+	}
+	if w.Code != http.StatusOK || session == nil || afterLogin == nil || afterLogin.Value == before.Value {
+		t.Fatalf("the login didn't hand out a new CSRF token: %d %v", w.Code, afterLogin)
+	}
+
+	// The token captured before logging in is refused with the new cookie
+	req, _ = http.NewRequest("POST", "/form", strings.NewReader(url.Values{"csrf_token": {before.Value}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(afterLogin)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("the old CSRF token was accepted after the login: %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/u/logout", nil)
+	req.AddCookie(session)
+	req.AddCookie(afterLogin)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var afterLogout *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "csrf" {
+			afterLogout = c
+		}
+	}
+	if afterLogout == nil || afterLogout.Value == afterLogin.Value {
+		t.Errorf("the logout didn't hand out a new CSRF token: %v", afterLogout)
+	}
+}
+
+// Helper function to create a router checking the CSRF token, with a route
+// taking a form and an exempt one
+func getCSRFRouter() *gin.Engine {
+	r := getRouter(true)
+	r.Use(middleware.CSRF(false, http.SameSiteLaxMode, "/webhook"))
+	r.GET("/form", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/form", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/webhook", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+// Helper function to visit a page and return the CSRF cookie handed out
+func getCSRFCookie(t *testing.T, r *gin.Engine) *http.Cookie {
+	req, _ := http.NewRequest("GET", "/form", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "csrf" {
+			if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+				t.Errorf("unexpected cookie attributes: %v", c)
+			}
+			return c
+		}
+	}
+	t.Fatal("no CSRF cookie was set")
+	return nil
+}
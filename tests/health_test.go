@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"GolangStore/database"
+	"GolangStore/handlers"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// A stand-in for the database that fails the given number of pings
+type fakePinger struct {
+	failures int
+	pings    int
+}
+
+func (p *fakePinger) PingContext(ctx context.Context) error {
+	p.pings++
+	if p.pings <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// Test the health checks when the database is reachable or not
+func TestHealthChecks(t *testing.T) {
+	tmpDatabase := handlers.Database
+	defer func() { handlers.Database = tmpDatabase }()
+
+	r := getRouter(false)
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", handlers.Readyz)
+
+	for _, test := range []struct {
+		db                    database.Pinger
+		path                  string
+		expectedHTTPCode      int
+		expectedDatabaseState string
+	}{
+		{nil, "/healthz", http.StatusOK, "memory"},
+		{nil, "/readyz", http.StatusOK, "memory"},
+		{&fakePinger{}, "/readyz", http.StatusOK, "ok"},
+		{&fakePinger{failures: 1}, "/healthz", http.StatusOK, "unreachable"},
+		{&fakePinger{failures: 1}, "/readyz", http.StatusServiceUnavailable, "unreachable"},
+	} {
+		handlers.Database = test.db
+		req, _ := http.NewRequest("GET", test.path, nil)
+
+		testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+			var status handlers.HealthStatus
+			err := json.Unmarshal(w.Body.Bytes(), &status)
+			return err == nil && w.Code == test.expectedHTTPCode && status.Database == test.expectedDatabaseState
+		})
+	}
+}
+
+// Test that the database is pinged again until it answers
+func TestWaitForDatabase(t *testing.T) {
+	db := &fakePinger{failures: 2}
+	if err := database.WaitForDatabase(context.Background(), db, 5, time.Millisecond); err != nil || db.pings != 3 {
+		t.Errorf("expected 3 pings and no error, got %d pings and %v", db.pings, err)
+	}
+
+	// The startup gives up after the last attempt
+	db = &fakePinger{failures: 10}
+	if err := database.WaitForDatabase(context.Background(), db, 3, time.Millisecond); err == nil || db.pings != 3 {
+		t.Errorf("expected 3 pings and an error, got %d pings and %v", db.pings, err)
+	}
+
+	// The wait is interrupted when shutting down
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db = &fakePinger{failures: 10}
+	if err := database.WaitForDatabase(ctx, db, 3, time.Hour); err != context.Canceled || db.pings != 1 {
+		t.Errorf("expected the wait to be canceled, got %d pings and %v", db.pings, err)
+	}
+}
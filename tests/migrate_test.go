@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"GolangStore/database"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Test that the migrations built into the binary can be read
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := database.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || !strings.Contains(migrations[0].Up, "create table if not exists products") {
+		t.Fatal("the first migration should create the products table")
+	}
+	// The databases set up from the older schema get the newer columns
+	for _, column := range []string{"role", "payment_id"} {
+		if !strings.Contains(migrations[0].Up, "add column if not exists "+column) {
+			t.Errorf("the first migration doesn't add the %s column to the existing tables", column)
+		}
+	}
+	// Undoing the first migration would drop the tables it adopted
+	if strings.Contains(migrations[0].Down, "drop table") || !strings.Contains(migrations[0].Down, "raise exception") {
+		t.Error("the first migration shouldn't be undone")
+	}
+	// The plain passwords of the older setups are hashed, as the logins only
+	// accept bcrypt hashes
+	hashed := false
+	for _, m := range migrations {
+		hashed = hashed || strings.Contains(m.Up, "crypt(password_hash, gen_salt('bf'")
+	}
+	if !hashed {
+		t.Error("no migration hashes the plain passwords")
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("the migration %d comes after %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+}
+
+// Test that the migrations are sorted by version and paired
+func TestReadMigrations(t *testing.T) {
+	migrations, err := database.ReadMigrations(fstest.MapFS{
+		"0010_add_index.up.sql":   {Data: []byte("create index")},
+		"0010_add_index.down.sql": {Data: []byte("drop index")},
+		"0002_add_table.up.sql":   {Data: []byte("create table")},
+		"0002_add_table.down.sql": {Data: []byte("drop table")},
+		"README.md":               {Data: []byte("not a migration")},
+	})
+	if err != nil || len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Name != "add_index" ||
+		migrations[1].Up != "create index" || migrations[1].Down != "drop index" {
+		t.Errorf("unexpected migrations %+v, %v", migrations, err)
+	}
+
+	for name, files := range map[string]fstest.MapFS{
+		"missing down": {"0001_add_table.up.sql": {Data: []byte("create table")}},
+		"bad name":     {"add_table.up.sql": {Data: []byte("create table")}},
+		"same version": {
+			"0001_add_table.up.sql":   {Data: []byte("create table")},
+			"0001_add_table.down.sql": {Data: []byte("drop table")},
+			"0001_add_index.up.sql":   {Data: []byte("create index")},
+			"0001_add_index.down.sql": {Data: []byte("drop index")},
+		},
+	} {
+		if _, err := database.ReadMigrations(files); err == nil {
+			t.Errorf("the migrations with a %s should have been rejected", name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the format picked for an article page with several Accept headers
+func TestArticleAcceptNegotiation(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/article/view/:article_id", handlers.GetArticle)
+
+	for accept, expectedContentType := range map[string]string{
+		"":                                    "text/html",
+		"*/*":                                 "text/html",
+		"application/json; charset=utf-8":     "application/json",
+		"text/html;q=0.5, application/json":   "application/json",
+		"application/xml;q=0.9, text/*;q=0.1": "application/xml",
+		"text/*":                              "text/html",
+		"application/*":                       "application/json",
+		"text/xml":                            "application/xml",
+		"application/json;q=0, */*;q=0.1":     "text/html",
+		"text/html,application/xml;q=0.9,*/*;q=0.8": "text/html",
+	} {
+		req, _ := http.NewRequest("GET", "/article/view/1", nil)
+		if accept != "" {
+			req.Header.Add("Accept", accept)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), expectedContentType) {
+			t.Errorf("%q: got %d %s", accept, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// Test that a request is refused when none of the formats is acceptable
+func TestArticleNotAcceptable(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/article/view/:article_id", handlers.GetArticle)
+
+	for _, url := range []string{"/article/view/1", "/article/view/1?format=pdf"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Add("Accept", "image/png, application/json;q=0")
+
+		testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+			return w.Code == http.StatusNotAcceptable
+		})
+	}
+}
+
+// Test that the format asked for in the URL wins over the Accept header
+func TestArticleFormatInURL(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/article/view/:article_id", handlers.GetArticle)
+	handler := middleware.FormatSuffix(r)
+
+	for url, expectedContentType := range map[string]string{
+		"/article/view/1?format=json": "application/json",
+		"/article/view/1.json":        "application/json",
+		"/article/view/1.xml":         "application/xml",
+		"/article/view/1.html":        "text/html",
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Add("Accept", "text/html")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), expectedContentType) {
+			t.Errorf("%s: got %d %s", url, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"GolangStore/routes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Test that every route of the application is described by the OpenAPI
+// document. Add an entry to routeDocs when this fails after adding a route
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	r := getRouter(false)
+	routes.InitializeRoutes(r)
+
+	if _, undocumented := routes.OpenAPIDocument(r.Routes()); len(undocumented) > 0 {
+		t.Errorf("routes without a spec entry: %s", strings.Join(undocumented, ", "))
+	}
+}
+
+// Test that a route without a spec entry is reported
+func TestOpenAPIUndocumentedRoute(t *testing.T) {
+	r := getRouter(false)
+	routes.InitializeRoutes(r)
+	r.GET("/undocumented/:thing_id", func(c *gin.Context) {})
+
+	doc, undocumented := routes.OpenAPIDocument(r.Routes())
+	if len(undocumented) != 1 || undocumented[0] != "GET /undocumented/:thing_id" {
+		t.Fail()
+	}
+
+	// The route is still listed along with its parameters
+	paths := doc["paths"].(gin.H)
+	if _, ok := paths["/undocumented/{thing_id}"]; !ok {
+		t.Fail()
+	}
+}
+
+// Test that the OpenAPI document is served as JSON
+func TestOpenAPIEndpoint(t *testing.T) {
+	r := getRouter(true)
+	routes.InitializeRoutes(r)
+
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		var doc struct {
+			OpenAPI string `json:"openapi"`
+			Paths   map[string]map[string]struct {
+				Parameters []struct {
+					Name string `json:"name"`
+					In   string `json:"in"`
+				} `json:"parameters"`
+			} `json:"paths"`
+			Components struct {
+				Schemas map[string]interface{} `json:"schemas"`
+			} `json:"components"`
+		}
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &doc) != nil {
+			return false
+		}
+
+		params := doc.Paths["/article/view/{article_id}"]["get"].Parameters
+		_, hasArticle := doc.Components.Schemas["Article"]
+		_, hasProduct := doc.Components.Schemas["Product"]
+		_, hasUser := doc.Components.Schemas["User"]
+
+		return strings.HasPrefix(doc.OpenAPI, "3.") && len(params) == 1 &&
+			params[0].Name == "article_id" && params[0].In == "path" &&
+			hasArticle && hasProduct && hasUser
+	})
+}
+
+// Test that the page browsing the OpenAPI document doesn't need the network
+func TestAPIDocsPage(t *testing.T) {
+	r := getRouter(true)
+	routes.InitializeRoutes(r)
+
+	req, _ := http.NewRequest("GET", "/api/docs", nil)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		body := w.Body.String()
+		return w.Code == http.StatusOK && strings.Contains(body, "/openapi.json") &&
+			strings.Contains(body, "/api/docs/assets/swagger-ui-bundle.js") &&
+			!strings.Contains(body, "https://")
+	})
+}
+
+// Test that the files of Swagger UI are served along with the application
+func TestAPIDocsAssets(t *testing.T) {
+	r := getRouter(false)
+	routes.InitializeRoutes(r)
+
+	for _, test := range []struct {
+		path, contentType string
+		expectedCode      int
+	}{
+		{"/api/docs/assets/swagger-ui-bundle.js", "javascript", http.StatusOK},
+		{"/api/docs/assets/swagger-ui.css", "text/css", http.StatusOK},
+		{"/api/docs/assets/favicon-32x32.png", "image/png", http.StatusOK},
+		{"/api/docs/assets/missing.js", "", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != test.expectedCode || !strings.Contains(w.Header().Get("Content-Type"), test.contentType) {
+			t.Errorf("%s: got %d %q, expected %d %q", test.path, w.Code,
+				w.Header().Get("Content-Type"), test.expectedCode, test.contentType)
+		}
+	}
+}
+
+// Test that the numeric form fields aren't described as strings
+func TestOpenAPIFormFieldTypes(t *testing.T) {
+	r := getRouter(false)
+	routes.InitializeRoutes(r)
+	doc, _ := routes.OpenAPIDocument(r.Routes())
+
+	for _, test := range []struct{ path, field, expectedType string }{
+		{"/product/create", "price", "number"},
+		{"/product/create", "quantity", "integer"},
+		{"/product/create", "name", "string"},
+		{"/cart/add", "product_id", "integer"},
+	} {
+		op := doc["paths"].(gin.H)[test.path].(gin.H)["post"].(gin.H)
+		content := op["requestBody"].(gin.H)["content"].(gin.H)["application/x-www-form-urlencoded"].(gin.H)
+		field := content["schema"].(gin.H)["properties"].(gin.H)[test.field].(gin.H)
+		if field["type"] != test.expectedType {
+			t.Errorf("%s %s is a %v", test.path, test.field, field["type"])
+		}
+	}
+}
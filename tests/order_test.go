@@ -0,0 +1,379 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/* =============================== MODELS TESTS =============================== */
+// Test that placing an order takes the bought quantities out of stock and
+// keeps the price of the products at that time
+func TestPlaceOrder(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+
+	order, err := orders.Place(context.Background(), "user1", []models.CartEntry{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}})
+	if err != nil || order.Status != models.OrderPending || len(order.Items) != 2 || order.Total != 201 {
+		t.Fatal(err)
+	}
+
+	if p, _ := products.ByID(context.Background(), 1); p.Quantity != 1 {
+		t.Fail()
+	}
+
+	// Changing the price later doesn't change the order
+	p, _ := products.ByID(context.Background(), 1)
+	p.Price = 1
+	products.Update(context.Background(), p)
+	if stored, err := orders.ByID(context.Background(), order.ID); err != nil || stored.Items[0].Price != 25.5 {
+		t.Fail()
+	}
+
+	if list, err := orders.ByUsername(context.Background(), "user1"); err != nil || len(list) != 1 {
+		t.Fail()
+	}
+}
+
+// Test that an order is rejected as a whole when one product lacks stock
+func TestPlaceOrderInsufficientStock(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+
+	_, err := orders.Place(context.Background(), "user1", []models.CartEntry{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 3}})
+	if err != models.ErrInsufficientStock {
+		t.Fail()
+	}
+
+	// The stock of the first product wasn't touched either
+	if p, _ := products.ByID(context.Background(), 1); p.Quantity != 3 {
+		t.Fail()
+	}
+	if list, _ := orders.ByUsername(context.Background(), "user1"); len(list) != 0 {
+		t.Fail()
+	}
+}
+
+// Test that a product listed twice in an order needs stock for both entries
+func TestPlaceOrderRepeatedProduct(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+
+	_, err := orders.Place(context.Background(), "user1", []models.CartEntry{{ProductID: 1, Quantity: 2}, {ProductID: 1, Quantity: 2}})
+	if err != models.ErrInsufficientStock {
+		t.Errorf("expected the stock to be insufficient, got %v", err)
+	}
+	if p, _ := products.ByID(context.Background(), 1); p.Quantity != 3 {
+		t.Errorf("the stock was changed to %d", p.Quantity)
+	}
+}
+
+// Test that concurrent orders never sell more units than available
+func TestPlaceOrdersConcurrently(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	placed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := orders.Place(context.Background(), "user1", []models.CartEntry{{ProductID: 1, Quantity: 1}}); err == nil {
+				mu.Lock()
+				placed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p, _ := products.ByID(context.Background(), 1); placed != 3 || p.Quantity != 0 {
+		t.Fail()
+	}
+}
+
+// Test that a cart checked out twice at once is only ordered and charged
+// once
+func TestCheckoutTwice(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+	carts := models.NewMemoryCartRepository()
+	payments := models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")
+	carts.SetQuantity(context.Background(), "user:user1", 1, 1)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	emptyCart := 0
+	for err := range errs {
+		if err == models.ErrEmptyCart {
+			emptyCart++
+		} else if err != nil {
+			t.Error(err)
+		}
+	}
+	if list, _ := orders.All(context.Background()); emptyCart != 1 || len(list) != 1 {
+		t.Errorf("expected a single order, got %d orders", len(list))
+	}
+}
+
+// Test that the Postgres repository loads the items of a list of orders with
+// a single query
+func TestPostgresOrderItems(t *testing.T) {
+	db, standIn := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		if strings.Contains(query, "from order_items") {
+			return &standInResult{
+				columns: []string{"order_id", "product_id", "name", "price", "quantity"},
+				rows: [][]driver.Value{
+					{int64(2), int64(1), "Keyboard", []byte("25.5"), int64(1)},
+					{int64(1), int64(2), "Monitor", []byte("150"), int64(1)},
+					{int64(2), int64(2), "Monitor", []byte("150"), int64(2)},
+				},
+			}, nil
+		}
+		res := &standInResult{columns: []string{"id", "username", "status", "total", "created_at", "payment_id"}}
+		for id := int64(3); id > 0; id-- {
+			res.rows = append(res.rows, []driver.Value{id, "user1", models.OrderPaid, []byte("0"), time.Now(), ""})
+		}
+		return res, nil
+	})
+
+	orders, err := models.NewPostgresOrderRepository(db).All(context.Background())
+	if err != nil || len(orders) != 3 {
+		t.Fatalf("unexpected orders %+v, %v", orders, err)
+	}
+	if len(orders[0].Items) != 0 || len(orders[1].Items) != 2 || orders[1].Items[1].Quantity != 2 ||
+		len(orders[2].Items) != 1 || orders[2].Items[0].Name != "Monitor" {
+		t.Errorf("the items weren't matched to their orders: %+v", orders)
+	}
+	if queries := standIn.received(); len(queries) != 2 {
+		t.Errorf("expected one query for the orders and one for the items, got %q", queries)
+	}
+}
+
+// Test the transitions allowed between the order statuses
+func TestOrderTransitions(t *testing.T) {
+	allowed := [][2]string{
+		{models.OrderPending, models.OrderPaid},
+		{models.OrderPending, models.OrderCancelled},
+		{models.OrderPaid, models.OrderShipped},
+		{models.OrderPaid, models.OrderRefunded},
+		{models.OrderShipped, models.OrderDelivered},
+		{models.OrderDelivered, models.OrderRefunded},
+	}
+	for _, transition := range allowed {
+		if !models.CanTransition(transition[0], transition[1]) {
+			t.Errorf("%s -> %s should be allowed", transition[0], transition[1])
+		}
+	}
+
+	forbidden := [][2]string{
+		{models.OrderPending, models.OrderShipped},
+		{models.OrderShipped, models.OrderPending},
+		{models.OrderCancelled, models.OrderPaid},
+		{models.OrderRefunded, models.OrderPaid},
+		{models.OrderPaid, "unknown"},
+	}
+	for _, transition := range forbidden {
+		if models.CanTransition(transition[0], transition[1]) {
+			t.Errorf("%s -> %s should not be allowed", transition[0], transition[1])
+		}
+	}
+}
+
+// Test that status changes are recorded in the history of the order
+func TestUpdateOrderStatus(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+	order, _ := orders.Place(context.Background(), "user1", []models.CartEntry{{ProductID: 1, Quantity: 1}})
+
+	if _, err := orders.UpdateStatus(context.Background(), order.ID, models.OrderShipped, "admin"); err != models.ErrInvalidTransition {
+		t.Fail()
+	}
+	if _, err := orders.UpdateStatus(context.Background(), order.ID, models.OrderPaid, "admin"); err != nil {
+		t.Fail()
+	}
+	if _, err := orders.UpdateStatus(context.Background(), 42, models.OrderPaid, "admin"); err != models.ErrOrderNotFound {
+		t.Fail()
+	}
+
+	stored, err := orders.ByID(context.Background(), order.ID)
+	if err != nil || stored.Status != models.OrderPaid || len(stored.History) != 2 {
+		t.Fatal(err)
+	}
+	if stored.History[0].From != "" || stored.History[0].To != models.OrderPending || stored.History[0].ChangedBy != "user1" ||
+		stored.History[1].From != models.OrderPending || stored.History[1].To != models.OrderPaid || stored.History[1].ChangedBy != "admin" {
+		t.Fail()
+	}
+}
+
+/* =============================== HANDLERS TESTS =============================== */
+// Test that a POST request to checkout places the order, empties the cart
+// and returns the order in JSON format
+func TestCheckoutAuthenticated(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	handlers.Carts = models.NewMemoryCartRepository()
+	handlers.Carts.SetQuantity(context.Background(), models.UserCartOwner("user1"), 2, 2)
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/checkout", middleware.EnsureLoggedIn(), handlers.Checkout)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/checkout", nil)
+	req.AddCookie(getSessionCookie(t))
+	req.Header.Add("Accept", "application/json")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		var order models.Order
+		err := json.Unmarshal(w.Body.Bytes(), &order)
+
+		return w.Code == http.StatusOK && err == nil && order.Username == "user1" &&
+			order.Total == 300 && len(order.Items) == 1
+	})
+
+	if entries, _ := handlers.Carts.Entries(context.Background(), models.UserCartOwner("user1")); len(entries) != 0 {
+		t.Fail()
+	}
+	if p, _ := handlers.Products.ByID(context.Background(), 2); p.Quantity != 0 {
+		t.Fail()
+	}
+
+	restoreLists()
+}
+
+// Test that checking out an empty cart shows the cart with an HTTP 400 error
+func TestCheckoutEmptyCart(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	handlers.Carts = models.NewMemoryCartRepository()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/checkout", middleware.EnsureLoggedIn(), handlers.Checkout)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/checkout", nil)
+	req.AddCookie(getSessionCookie(t))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusBadRequest && strings.Contains(w.Body.String(), "the cart is empty")
+	})
+
+	restoreLists()
+}
+
+// Test that a user can see its own orders with their timeline but not the
+// orders of other users
+func TestGetOrder(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	own, _ := handlers.Orders.Place(context.Background(), "user1", []models.CartEntry{{ProductID: 1, Quantity: 1}})
+	other, _ := handlers.Orders.Place(context.Background(), "user2", []models.CartEntry{{ProductID: 1, Quantity: 1}})
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/orders/view/:order_id", middleware.EnsureLoggedIn(), handlers.GetOrder)
+
+	cookie := getSessionCookie(t)
+	req, _ := http.NewRequest("GET", "/orders/view/"+strconv.Itoa(own.ID), nil)
+	req.AddCookie(cookie)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusOK && strings.Contains(w.Body.String(), "<h2>Timeline</h2>")
+	})
+
+	req, _ = http.NewRequest("GET", "/orders/view/"+strconv.Itoa(other.ID), nil)
+	req.AddCookie(cookie)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusNotFound
+	})
+
+	restoreLists()
+}
+
+// Test that the administration route moves an order to the POSTed status
+// and rejects the statuses that can't be reached
+func TestUpdateOrderStatusHandler(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	order, _ := handlers.Orders.Place(context.Background(), "user2", []models.CartEntry{{ProductID: 1, Quantity: 1}})
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/admin/orders/status/:order_id", middleware.RequireRole(models.RoleAdmin), handlers.UpdateOrderStatus)
+
+	cookie := getSessionCookie(t)
+	path := "/admin/orders/status/" + strconv.Itoa(order.ID)
+
+	req := getStatusPOSTRequest(path, models.OrderShipped)
+	req.AddCookie(cookie)
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusConflict
+	})
+
+	req = getStatusPOSTRequest(path, models.OrderPaid)
+	req.AddCookie(cookie)
+	req.Header.Add("Accept", "application/json")
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		var updated models.Order
+		err := json.Unmarshal(w.Body.Bytes(), &updated)
+		return w.Code == http.StatusOK && err == nil && updated.Status == models.OrderPaid &&
+			len(updated.History) == 2 && updated.History[1].ChangedBy == "user1"
+	})
+
+	restoreLists()
+}
+
+// Test that a POST request to checkout returns an HTTP 401 error
+// for an unauthorized user
+func TestCheckoutUnauthenticated(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/checkout", middleware.EnsureLoggedIn(), handlers.Checkout)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/checkout", nil)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusUnauthorized
+	})
+}
+
+// Helper function to build a POST request changing the status of an order
+func getStatusPOSTRequest(path, status string) *http.Request {
+	params := url.Values{}
+	params.Add("status", status)
+	payload := params.Encode()
+
+	req, _ := http.NewRequest("POST", path, strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(payload)))
+	return req
+}
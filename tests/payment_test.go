@@ -0,0 +1,360 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+/* =============================== MODELS TESTS =============================== */
+// Test that a successful checkout captures the payment and marks the order as paid
+func TestCheckoutPaymentSucceeds(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+	carts := models.NewMemoryCartRepository()
+	payments := models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")
+	carts.SetQuantity(context.Background(), "user:user1", 1, 2)
+
+	order, err := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1")
+	if err != nil || order.Status != models.OrderPaid || order.PaymentID != "fake_auth_1" {
+		t.Fatal(err)
+	}
+	if captured, _, err := payments.Payment(order.PaymentID); err != nil || !captured {
+		t.Fail()
+	}
+	if entries, _ := carts.Entries(context.Background(), "user:user1"); len(entries) != 0 {
+		t.Fail()
+	}
+}
+
+// Test that a declined or timed out payment cancels the order, puts the
+// products back in stock and keeps the cart
+func TestCheckoutPaymentFails(t *testing.T) {
+	for mode, expected := range map[string]error{
+		models.FakePaymentDecline: models.ErrPaymentDeclined,
+		models.FakePaymentTimeout: models.ErrPaymentTimeout,
+	} {
+		products := models.NewMemoryProductRepository(getTestProducts()...)
+		orders := models.NewMemoryOrderRepository(products)
+		carts := models.NewMemoryCartRepository()
+		payments := models.NewFakePaymentGateway(mode, "secret")
+		payments.TimeoutDelay = 10 * time.Millisecond
+		carts.SetQuantity(context.Background(), "user:user1", 1, 2)
+
+		if _, err := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1"); err != expected {
+			t.Errorf("%s: got %v", mode, err)
+		}
+
+		if list, _ := orders.All(context.Background()); len(list) != 1 || list[0].Status != models.OrderCancelled {
+			t.Errorf("%s: the order wasn't cancelled", mode)
+		}
+		if p, _ := products.ByID(context.Background(), 1); p.Quantity != 3 {
+			t.Errorf("%s: the stock wasn't restored", mode)
+		}
+		if entries, _ := carts.Entries(context.Background(), "user:user1"); len(entries) != 1 {
+			t.Errorf("%s: the cart wasn't kept", mode)
+		}
+	}
+}
+
+// Test that a captured payment which can't be recorded on its order is
+// refunded and the order cancelled, so that the cart can't be paid twice
+func TestCheckoutPaidStatusFails(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := &failingPaidOrders{OrderRepository: models.NewMemoryOrderRepository(products), failures: 5}
+	carts := models.NewMemoryCartRepository()
+	payments := models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")
+	carts.SetQuantity(context.Background(), "user:user1", 1, 2)
+
+	if _, err := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1"); err != errPaidStatus {
+		t.Fatalf("expected the status error, got %v", err)
+	}
+	list, _ := orders.All(context.Background())
+	if len(list) != 1 || list[0].Status != models.OrderCancelled {
+		t.Fatalf("the order wasn't cancelled: %+v", list)
+	}
+	if _, refunded, err := payments.Payment(list[0].PaymentID); err != nil || refunded != list[0].Total {
+		t.Errorf("the payment wasn't refunded: %v %v", refunded, err)
+	}
+	if entries, _ := carts.Entries(context.Background(), "user:user1"); len(entries) != 1 {
+		t.Error("the cart wasn't kept")
+	}
+
+	// A failure that doesn't last is retried
+	orders.failures = 1
+	order, err := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1")
+	if err != nil || order.Status != models.OrderPaid {
+		t.Errorf("the retry should have marked the order as paid: %v", err)
+	}
+}
+
+// Test that a refund failing after a payment went wrong is reported and
+// leaves the order pending with its payment
+func TestCheckoutRefundFails(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := &failingPaidOrders{OrderRepository: models.NewMemoryOrderRepository(products), failures: 5}
+	carts := models.NewMemoryCartRepository()
+	payments := failingRefunds{models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")}
+	carts.SetQuantity(context.Background(), "user:user1", 1, 2)
+
+	_, err := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1")
+	var refundErr *models.RefundError
+	if !errors.As(err, &refundErr) || refundErr.Cause != errPaidStatus || refundErr.AuthorizationID == "" {
+		t.Fatalf("expected a refund error, got %v", err)
+	}
+	if list, _ := orders.All(context.Background()); len(list) != 1 || list[0].Status != models.OrderPending ||
+		list[0].PaymentID != refundErr.AuthorizationID {
+		t.Errorf("the order should be left pending with its payment: %+v", list)
+	}
+}
+
+// Test that a client going away once the order is placed doesn't stop the
+// payment
+func TestCheckoutClientGoesAway(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	ctx, cancel := context.WithCancel(context.Background())
+	orders := cancelAfterPlace{models.NewMemoryOrderRepository(products), cancel}
+	carts := models.NewMemoryCartRepository()
+	payments := models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")
+	carts.SetQuantity(context.Background(), "user:user1", 1, 2)
+
+	order, err := models.Checkout(ctx, carts, orders, payments, "user:user1", "user1")
+	if err != nil || order.Status != models.OrderPaid {
+		t.Fatalf("the order should have been paid: %v", err)
+	}
+	if captured, _, _ := payments.Payment(order.PaymentID); !captured {
+		t.Error("the payment wasn't captured")
+	}
+}
+
+// Test that refunding an order gives its payment back
+func TestRefundOrder(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	orders := models.NewMemoryOrderRepository(products)
+	carts := models.NewMemoryCartRepository()
+	payments := models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")
+	carts.SetQuantity(context.Background(), "user:user1", 2, 1)
+	order, _ := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1")
+
+	refunded, err := models.ChangeOrderStatus(context.Background(), orders, payments, order.ID, models.OrderRefunded, "admin")
+	if err != nil || refunded.Status != models.OrderRefunded {
+		t.Fatal(err)
+	}
+	if _, amount, _ := payments.Payment(order.PaymentID); amount != 150 {
+		t.Fail()
+	}
+
+	// A refunded order can't be refunded again
+	if _, err := models.ChangeOrderStatus(context.Background(), orders, payments, order.ID, models.OrderRefunded, "admin"); err != models.ErrInvalidTransition {
+		t.Fail()
+	}
+}
+
+// Test that an order whose status couldn't be saved after its refund is
+// moved to refunded by a retry, without giving the payment back twice
+func TestRefundOrderStatusFails(t *testing.T) {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	memoryOrders := models.NewMemoryOrderRepository(products)
+	orders := &failingRefundedOrders{OrderRepository: memoryOrders, failures: 1}
+	carts := models.NewMemoryCartRepository()
+	payments := models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")
+	carts.SetQuantity(context.Background(), "user:user1", 2, 1)
+	order, _ := models.Checkout(context.Background(), carts, orders, payments, "user:user1", "user1")
+
+	if _, err := models.ChangeOrderStatus(context.Background(), orders, payments, order.ID, models.OrderRefunded, "admin"); err != errRefundedStatus {
+		t.Fatalf("expected the status change to fail, got %v", err)
+	}
+	if stored, _ := orders.ByID(context.Background(), order.ID); stored.Status != models.OrderPaid {
+		t.Errorf("the order should still be paid, it is %s", stored.Status)
+	}
+
+	refunded, err := models.ChangeOrderStatus(context.Background(), orders, payments, order.ID, models.OrderRefunded, "admin")
+	if err != nil || refunded.Status != models.OrderRefunded {
+		t.Fatalf("the retry should have refunded the order: %v", err)
+	}
+	if _, amount, _ := payments.Payment(order.PaymentID); amount != 150 {
+		t.Errorf("unexpected refunded amount %v", amount)
+	}
+}
+
+// Test that the fake gateway in timeout mode waits for the deadline of the
+// call, or for its delay when there's none
+func TestFakePaymentTimeout(t *testing.T) {
+	payments := models.NewFakePaymentGateway(models.FakePaymentTimeout, "secret")
+	payments.TimeoutDelay = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := payments.Authorize(ctx, 10, "order-1"); err != models.ErrPaymentTimeout {
+		t.Errorf("expected the payment to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("the call didn't wait for its deadline, it took %s", elapsed)
+	}
+
+	payments.TimeoutDelay = 20 * time.Millisecond
+	start = time.Now()
+	if err := payments.Capture(context.Background(), "fake_auth_1"); err != models.ErrPaymentTimeout {
+		t.Errorf("expected the capture to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("the call didn't wait for the delay, it took %s", elapsed)
+	}
+}
+
+/* =============================== HANDLERS TESTS =============================== */
+// Test that a declined payment shows the cart with an HTTP 402 error
+func TestCheckoutDeclined(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	handlers.Carts = models.NewMemoryCartRepository()
+	handlers.Carts.SetQuantity(context.Background(), models.UserCartOwner("user1"), 1, 1)
+	handlers.Payments = models.NewFakePaymentGateway(models.FakePaymentDecline, "secret")
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/checkout", middleware.EnsureLoggedIn(), handlers.Checkout)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/checkout", nil)
+	req.AddCookie(getSessionCookie(t))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusPaymentRequired
+	})
+
+	restoreLists()
+}
+
+// Test that the webhook only accepts payloads signed by the provider and
+// applies their event to the order
+func TestPaymentWebhook(t *testing.T) {
+	saveLists()
+	useTestProducts()
+	payments := models.NewFakePaymentGateway(models.FakePaymentSucceed, "secret")
+	handlers.Payments = payments
+	order, _ := handlers.Orders.Place(context.Background(), "user1", []models.CartEntry{{ProductID: 1, Quantity: 1}})
+	r := getRouter(false)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/payments/webhook", handlers.PaymentWebhook)
+
+	payload, _ := json.Marshal(models.PaymentEvent{
+		Type:      models.PaymentCaptured,
+		Reference: models.OrderPaymentReference(order.ID),
+		Amount:    order.Total,
+	})
+
+	// A payload with a bad signature is rejected
+	req, _ := http.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	req.Header.Add("X-Payment-Signature", "00")
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusUnauthorized
+	})
+
+	req, _ = http.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	req.Header.Add("X-Payment-Signature", payments.SignWebhook(payload))
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusNoContent
+	})
+
+	if stored, _ := handlers.Orders.ByID(context.Background(), order.ID); stored.Status != models.OrderPaid {
+		t.Errorf("order %s is %s", strconv.Itoa(order.ID), stored.Status)
+	}
+
+	// Without a secret the payloads signed with an empty key are refused
+	unsigned := models.NewFakePaymentGateway(models.FakePaymentSucceed, "")
+	handlers.Payments = unsigned
+	req, _ = http.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	req.Header.Add("X-Payment-Signature", unsigned.SignWebhook(payload))
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusNotFound
+	})
+
+	restoreLists()
+}
+
+var errPaidStatus = errors.New("the order can't be saved")
+
+// Order repository failing to mark the orders as paid the given number of
+// times
+type failingPaidOrders struct {
+	models.OrderRepository
+	failures int
+}
+
+func (o *failingPaidOrders) UpdateStatus(ctx context.Context, id int, status, changedBy string) (*models.Order, error) {
+	if status == models.OrderPaid && o.failures > 0 {
+		o.failures--
+		return nil, errPaidStatus
+	}
+	return o.OrderRepository.UpdateStatus(ctx, id, status, changedBy)
+}
+
+var errRefundedStatus = errors.New("the refunded order can't be saved")
+
+// Order repository failing to save the refunded status the given number of
+// times, once the refund is done
+type failingRefundedOrders struct {
+	models.OrderRepository
+	failures int
+}
+
+func (o *failingRefundedOrders) UpdateStatusAfter(ctx context.Context, id int, status, changedBy string, before func(*models.Order) error) (*models.Order, error) {
+	if status != models.OrderRefunded || o.failures == 0 {
+		return o.OrderRepository.UpdateStatusAfter(ctx, id, status, changedBy, before)
+	}
+	o.failures--
+	return o.OrderRepository.UpdateStatusAfter(ctx, id, status, changedBy, func(order *models.Order) error {
+		if err := before(order); err != nil {
+			return err
+		}
+		return errRefundedStatus
+	})
+}
+
+// Payment gateway whose refunds always fail
+type failingRefunds struct {
+	*models.FakePaymentGateway
+}
+
+func (failingRefunds) Refund(ctx context.Context, authorizationID string, amount float64) error {
+	return models.ErrPaymentTimeout
+}
+
+// Order repository cancelling the request once an order is placed, and
+// failing like the Postgres one on the changes made with a cancelled context
+type cancelAfterPlace struct {
+	models.OrderRepository
+	cancel context.CancelFunc
+}
+
+func (o cancelAfterPlace) Place(ctx context.Context, username string, entries []models.CartEntry) (*models.Order, error) {
+	order, err := o.OrderRepository.Place(ctx, username, entries)
+	o.cancel()
+	return order, err
+}
+
+func (o cancelAfterPlace) UpdateStatus(ctx context.Context, id int, status, changedBy string) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return o.OrderRepository.UpdateStatus(ctx, id, status, changedBy)
+}
+
+func (o cancelAfterPlace) AttachPayment(ctx context.Context, id int, paymentID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.OrderRepository.AttachPayment(ctx, id, paymentID)
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// Test that the products are read with explicit columns
+func TestPostgresProductsAll(t *testing.T) {
+	db, standIn := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return productRows(
+			[]interface{}{1, "Keyboard", "A keyboard", 25.5, 3},
+			[]interface{}{2, "Monitor", "A monitor", 150.0, 2},
+		), nil
+	})
+	products, err := models.NewPostgresProductRepository(db).All(context.Background())
+	if err != nil || len(products) != 2 || products[1].Name != "Monitor" || products[0].Price != 25.5 {
+		t.Fatalf("unexpected products %+v, %v", products, err)
+	}
+
+	query := standIn.received()[0]
+	if strings.Contains(query, "*") || !strings.Contains(query, "id, name, description, price, quantity") {
+		t.Errorf("the columns aren't listed in %q", query)
+	}
+}
+
+// Test that an error while reading the rows isn't mistaken for the end of
+// the list
+func TestPostgresProductsRowsError(t *testing.T) {
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		res := productRows([]interface{}{1, "Keyboard", "A keyboard", 25.5, 3})
+		res.rowsErr = &pq.Error{Code: "08006", Message: "connection failure"}
+		return res, nil
+	})
+	products, err := models.NewPostgresProductRepository(db).All(context.Background())
+	if !errors.Is(err, models.ErrUnavailable) || products != nil {
+		t.Errorf("expected the list to fail as unavailable, got %+v and %v", products, err)
+	}
+}
+
+// Test how the errors of the database are reported by the repository
+func TestPostgresProductErrors(t *testing.T) {
+	for _, test := range []struct {
+		err         error
+		unavailable bool
+	}{
+		{&pq.Error{Code: "57P03", Message: "the database system is starting up"}, true},
+		{&pq.Error{Code: "53300", Message: "too many connections"}, true},
+		{&pq.Error{Code: "42703", Message: "column does not exist"}, false},
+	} {
+		db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+			return nil, test.err
+		})
+		_, err := models.NewPostgresProductRepository(db).ByID(context.Background(), 1)
+		if err == nil || errors.Is(err, models.ErrUnavailable) != test.unavailable {
+			t.Errorf("%v: unexpected error %v", test.err, err)
+		}
+		db.Close()
+	}
+
+	// A missing row is a missing product
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return productRows(), nil
+	})
+	if _, err := models.NewPostgresProductRepository(db).ByID(context.Background(), 1); err != models.ErrProductNotFound {
+		t.Errorf("expected the product not to be found, got %v", err)
+	}
+
+	// The queries of a cancelled request aren't run
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := models.NewPostgresProductRepository(db).All(ctx); err == nil {
+		t.Error("the query of a cancelled request should have failed")
+	}
+}
+
+// Test that the failures of the database are shown as 503 and 500 pages
+func TestProductPagesOnDatabaseFailure(t *testing.T) {
+	saveLists()
+	defer restoreLists()
+
+	for _, test := range []struct {
+		err              error
+		expectedHTTPCode int
+		expectedMessage  string
+	}{
+		{&pq.Error{Code: "08006", Message: "connection failure"}, http.StatusServiceUnavailable, "temporarily unavailable"},
+		{&pq.Error{Code: "42P01", Message: "relation does not exist"}, http.StatusInternalServerError, "went wrong"},
+	} {
+		db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+			return nil, test.err
+		})
+		handlers.Products = models.NewPostgresProductRepository(db)
+
+		r := getRouter(true)
+		r.GET("/products", handlers.IndexPage)
+		req, _ := http.NewRequest("GET", "/products", nil)
+
+		testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+			body := w.Body.String()
+			// The cause is logged but not shown
+			return w.Code == test.expectedHTTPCode && strings.Contains(body, test.expectedMessage) &&
+				!strings.Contains(body, "relation") && !strings.Contains(body, "connection failure")
+		})
+		db.Close()
+	}
+}
+
+// Test that the API reports an unavailable database with a 503
+func TestAPIProductsOnDatabaseFailure(t *testing.T) {
+	saveLists()
+	defer restoreLists()
+
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return nil, &pq.Error{Code: "08006", Message: "connection failure"}
+	})
+	handlers.Products = models.NewPostgresProductRepository(db)
+
+	w := serveAPIRequest(getAPIRouter(), "GET", "/api/v1/products", "", nil)
+	if w.Code != http.StatusServiceUnavailable || apiErrorCode(w) != middleware.ErrCodeUnavailable ||
+		w.Header().Get("Retry-After") == "" {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,365 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/* =============================== MODELS TESTS =============================== */
+// Test the validation of the product fields
+func TestProductValidation(t *testing.T) {
+	p := models.Product{Name: "  Keyboard  ", Price: 10, Quantity: 1}
+	if err := p.Validate(); err != nil || p.Name != "Keyboard" {
+		t.Fail()
+	}
+
+	for _, p := range []models.Product{
+		{Name: " ", Price: 10, Quantity: 1},
+		{Name: "Keyboard", Price: -1, Quantity: 1},
+		{Name: "Keyboard", Price: 10, Quantity: -1},
+		{Name: "Keyboard", Price: math.NaN(), Quantity: 1},
+		{Name: "Keyboard", Price: math.Inf(1), Quantity: 1},
+	} {
+		if p.Validate() == nil {
+			t.Fail()
+		}
+	}
+}
+
+// Test the in-memory product repository
+func TestMemoryProductRepository(t *testing.T) {
+	repo := models.NewMemoryProductRepository(getTestProducts()...)
+
+	// New products get an ID after the highest seeded one
+	p := models.Product{Name: "Mouse", Price: 5, Quantity: 10}
+	if err := repo.Create(context.Background(), &p); err != nil || p.Id != 3 {
+		t.Fail()
+	}
+
+	p.Quantity = 9
+	if err := repo.Update(context.Background(), &p); err != nil {
+		t.Fail()
+	}
+	if stored, err := repo.ByID(context.Background(), 3); err != nil || stored.Quantity != 9 {
+		t.Fail()
+	}
+
+	if err := repo.Delete(context.Background(), 1); err != nil {
+		t.Fail()
+	}
+	if _, err := repo.ByID(context.Background(), 1); err != models.ErrProductNotFound {
+		t.Fail()
+	}
+	if err := repo.Delete(context.Background(), 1); err != models.ErrProductNotFound {
+		t.Fail()
+	}
+
+	if products, err := repo.All(context.Background()); err != nil || len(products) != 2 {
+		t.Fail()
+	}
+}
+
+/* =============================== HANDLERS TESTS =============================== */
+// Test that a GET request to the product list returns the products
+// in JSON format when the Accept header is set to application/json
+func TestProductListJSON(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/products", handlers.IndexPage)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/products", nil)
+	req.Header.Add("Accept", "application/json")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		var products []models.Product
+		err := json.Unmarshal(w.Body.Bytes(), &products)
+
+		return w.Code == http.StatusOK && err == nil && len(products) == 2 &&
+			products[0].Name == "Keyboard"
+	})
+}
+
+// Test that a GET request to the product list returns the products in CSV
+// format when the Accept header is set to text/csv
+func TestProductListCSV(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/products", handlers.IndexPage)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/products", nil)
+	req.Header.Add("Accept", "text/csv")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		expected := "id,name,description,price,quantity\n" +
+			"1,Keyboard,A keyboard,25.5,3\n" +
+			"2,Monitor,A monitor,150,2\n"
+
+		return w.Code == http.StatusOK && w.Body.String() == expected &&
+			strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv")
+	})
+}
+
+// Test that the text cells that would run as a formula in a spreadsheet are
+// neutralized, and read back as they were
+func TestProductCSVFormulas(t *testing.T) {
+	products := []*models.Product{
+		{Id: 1, Name: "=HYPERLINK(\"http://example.com\")", Description: "+1", Price: 2, Quantity: 3},
+		{Id: 2, Name: "@SUM(A1)", Description: "'quoted", Price: 2, Quantity: 3},
+	}
+	var buf bytes.Buffer
+	if err := models.WriteCSV(&buf, products); err != nil {
+		t.Fatal(err)
+	}
+	for _, cell := range []string{`"'=HYPERLINK(""http://example.com"")"`, "'+1", "'@SUM(A1)", "''quoted"} {
+		if !strings.Contains(buf.String(), cell) {
+			t.Errorf("%s isn't neutralized in:\n%s", cell, buf.String())
+		}
+	}
+
+	read := []models.Product{}
+	if err := models.ReadCSV(&buf, &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 2 || read[0].Name != products[0].Name || read[0].Description != "+1" ||
+		read[1].Description != "'quoted" {
+		t.Errorf("unexpected products %+v", read)
+	}
+
+	// A missing element is reported instead of crashing
+	if err := models.WriteCSV(&buf, []*models.Product{nil}); err == nil {
+		t.Error("the nil product should have been rejected")
+	}
+}
+
+// Test that a GET request to a product page returns the product page
+func TestProductView(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/product/view/:product_id", handlers.GetProduct)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/product/view/2", nil)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		p, err := ioutil.ReadAll(w.Body)
+		pageOK := err == nil && strings.Contains(string(p), "<title>Monitor</title>")
+
+		return w.Code == http.StatusOK && pageOK
+	})
+
+	// A product that doesn't exist is reported as not found
+	req, _ = http.NewRequest("GET", "/product/view/42", nil)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusNotFound
+	})
+}
+
+// Test that a POST request to create a product stores it and returns
+// a success message for an authenticated user
+func TestProductCreationAuthenticated(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/product/create", middleware.RequireRole(models.RoleAdmin), handlers.CreateProduct)
+
+	// Create a request to send to the above route
+	productPayload := getProductPOSTPayload("Mouse", "5.25", "8")
+	req, _ := http.NewRequest("POST", "/product/create", strings.NewReader(productPayload))
+	req.AddCookie(getSessionCookie(t))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(productPayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		p, err := ioutil.ReadAll(w.Body)
+		pageOK := err == nil && strings.Contains(string(p), "<title>Product Created</title>")
+
+		return w.Code == http.StatusOK && pageOK
+	})
+
+	if p, err := handlers.Products.ByID(context.Background(), 3); err != nil || p.Name != "Mouse" || p.Price != 5.25 || p.Quantity != 8 {
+		t.Fail()
+	}
+}
+
+// Test that a POST request to edit a product updates it
+func TestProductUpdateAuthenticated(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/product/edit/:product_id", middleware.RequireRole(models.RoleAdmin), handlers.UpdateProduct)
+
+	// Create a request to send to the above route
+	productPayload := getProductPOSTPayload("Mechanical Keyboard", "99", "1")
+	req, _ := http.NewRequest("POST", "/product/edit/1", strings.NewReader(productPayload))
+	req.AddCookie(getSessionCookie(t))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(productPayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusOK
+	})
+
+	if p, err := handlers.Products.ByID(context.Background(), 1); err != nil || p.Name != "Mechanical Keyboard" || p.Quantity != 1 {
+		t.Fail()
+	}
+}
+
+// Test that a POST request to delete a product removes it
+func TestProductDeleteAuthenticated(t *testing.T) {
+	useTestProducts()
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/product/delete/:product_id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteProduct)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("POST", "/product/delete/1", nil)
+	req.AddCookie(getSessionCookie(t))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusOK
+	})
+
+	if _, err := handlers.Products.ByID(context.Background(), 1); err != models.ErrProductNotFound {
+		t.Fail()
+	}
+}
+
+// Test that a GET request to the product creation page returns the
+// product creation page with the HTTP code 200 for an authenticated user
+func TestProductCreationPageAuthenticated(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/product/create", middleware.RequireRole(models.RoleAdmin), handlers.ShowProductCreationPage)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/product/create", nil)
+	req.AddCookie(getSessionCookie(t))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		p, err := ioutil.ReadAll(w.Body)
+		pageOK := err == nil && strings.Contains(string(p), "<title>Create New Product</title>")
+
+		return w.Code == http.StatusOK && pageOK
+	})
+}
+
+// Test that a POST request to create a product returns
+// an HTTP 401 error for an unauthorized user
+func TestProductCreationUnauthenticated(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/product/create", middleware.RequireRole(models.RoleAdmin), handlers.CreateProduct)
+
+	// Create a request to send to the above route
+	productPayload := getProductPOSTPayload("Keyboard", "10.5", "3")
+	req, _ := http.NewRequest("POST", "/product/create", strings.NewReader(productPayload))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(productPayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		// Test that the http status code is 401
+		return w.Code == http.StatusUnauthorized
+	})
+}
+
+// Test that a POST request with invalid values shows the form again
+// with an HTTP 400 error
+func TestProductCreationInvalid(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/product/create", middleware.RequireRole(models.RoleAdmin), handlers.CreateProduct)
+
+	// Create a request with a non numeric price
+	productPayload := getProductPOSTPayload("Keyboard", "cheap", "3")
+	req, _ := http.NewRequest("POST", "/product/create", strings.NewReader(productPayload))
+	req.AddCookie(getSessionCookie(t))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(productPayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		p, err := ioutil.ReadAll(w.Body)
+		pageOK := err == nil && strings.Contains(string(p), "the price field is invalid")
+
+		return w.Code == http.StatusBadRequest && pageOK
+	})
+
+	// The prices that aren't numbers are rejected too
+	for _, price := range []string{"NaN", "Inf", "1e400"} {
+		productPayload = getProductPOSTPayload("Keyboard", price, "3")
+		req, _ = http.NewRequest("POST", "/product/create", strings.NewReader(productPayload))
+		req.AddCookie(getSessionCookie(t))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+			return w.Code == http.StatusBadRequest
+		})
+	}
+}
+
+// Test that a GET request with an invalid product ID returns
+// an HTTP 404 error
+func TestProductInvalidID(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/product/view/:product_id", handlers.GetProduct)
+
+	// Create a request to send to the above route
+	req, _ := http.NewRequest("GET", "/product/view/abc", nil)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusNotFound
+	})
+}
+
+// Replace the product repository with a fresh one holding the test products,
+// along with the order repository taking its stock
+func useTestProducts() {
+	products := models.NewMemoryProductRepository(getTestProducts()...)
+	handlers.Products = products
+	handlers.Orders = models.NewMemoryOrderRepository(products)
+}
+
+func getTestProducts() []models.Product {
+	return []models.Product{
+		{Id: 1, Name: "Keyboard", Description: "A keyboard", Price: 25.5, Quantity: 3},
+		{Id: 2, Name: "Monitor", Description: "A monitor", Price: 150, Quantity: 2},
+	}
+}
+
+func getProductPOSTPayload(name, price, quantity string) string {
+	params := url.Values{}
+	params.Add("name", name)
+	params.Add("description", "Test Product Description")
+	params.Add("price", price)
+	params.Add("quantity", quantity)
+
+	return params.Encode()
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"GolangStore/handlers"
+	"GolangStore/middleware"
+	"GolangStore/models"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Test that the queries of a cancelled request are reported as cancelled
+func TestPostgresQueryCancelled(t *testing.T) {
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return productRows(), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := models.NewPostgresProductRepository(db).All(ctx)
+	var canceled *models.CanceledError
+	if !errors.As(err, &canceled) || canceled.Timeout() || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the query to be cancelled, got %v", err)
+	}
+}
+
+// Test that a query taking longer than the timeout is stopped
+func TestPostgresQueryTimeout(t *testing.T) {
+	setQueryTimeout(t, 20*time.Millisecond)
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		res := productRows()
+		res.delay = time.Minute
+		return res, nil
+	})
+
+	start := time.Now()
+	_, err := models.NewPostgresOrderRepository(db).All(context.Background())
+	var canceled *models.CanceledError
+	if !errors.As(err, &canceled) || !canceled.Timeout() || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the query to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the query wasn't stopped by the timeout, it took %s", elapsed)
+	}
+
+	// The statements cancelled by the server are timeouts too
+	db, _ = openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return nil, &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"}
+	})
+	err = models.NewPostgresUserRepository(db).Create(context.Background(), &models.User{Username: "user4"})
+	if !errors.As(err, &canceled) || !canceled.Timeout() {
+		t.Errorf("expected the statement to time out, got %v", err)
+	}
+}
+
+// Test how the handlers report the queries that were stopped
+func TestQueryCancellationResponses(t *testing.T) {
+	saveLists()
+	defer restoreLists()
+	setQueryTimeout(t, 20*time.Millisecond)
+
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		res := productRows()
+		res.delay = time.Minute
+		return res, nil
+	})
+	handlers.Products = models.NewPostgresProductRepository(db)
+
+	// A query taking too long is a temporary failure
+	r := getRouter(true)
+	r.GET("/products", handlers.IndexPage)
+	req, _ := http.NewRequest("GET", "/products", nil)
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != ""
+	})
+
+	w := serveAPIRequest(getAPIRouter(), "GET", "/api/v1/products", "", nil)
+	if w.Code != http.StatusServiceUnavailable || apiErrorCode(w) != middleware.ErrCodeTimeout {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+
+	// The request of a client that went away stops its queries
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", "/products", nil)
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == 499
+	})
+}
+
+// Helper function to change the timeout of the queries for the duration of
+// a test
+func setQueryTimeout(t *testing.T, timeout time.Duration) {
+	previous := models.QueryTimeout
+	models.QueryTimeout = timeout
+	t.Cleanup(func() { models.QueryTimeout = previous })
+}
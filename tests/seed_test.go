@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"GolangStore/models"
+	"GolangStore/seed"
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test that the same seed always generates the same data
+func TestGenerateIsDeterministic(t *testing.T) {
+	first := seed.Generate(42, seed.DefaultSize)
+	if !reflect.DeepEqual(first, seed.Generate(42, seed.DefaultSize)) {
+		t.Error("the same seed generated different data")
+	}
+	if reflect.DeepEqual(first, seed.Generate(43, seed.DefaultSize)) {
+		t.Error("another seed generated the same data")
+	}
+	if len(first.Users) != seed.DefaultSize.Users || len(first.Products) != seed.DefaultSize.Products ||
+		len(first.Orders) != seed.DefaultSize.Orders {
+		t.Errorf("the generated data doesn't have the requested size")
+	}
+}
+
+// Test storing generated data in the in-memory repositories
+func TestApplyGeneratedFixture(t *testing.T) {
+	target := newSeedTarget()
+	f := seed.Generate(7, seed.DefaultSize)
+
+	summary, err := seed.Apply(context.Background(), f, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := seed.Summary{Users: len(f.Users), Articles: len(f.Articles), Products: len(f.Products),
+		Categories: len(f.Categories), Orders: len(f.Orders)}
+	if *summary != expected {
+		t.Errorf("expected %+v to be stored, got %+v", expected, *summary)
+	}
+
+	// The orders reached the status of the fixture
+	orders, _ := target.Orders.All(context.Background())
+	for _, o := range orders {
+		if o.Status != f.Orders[o.ID-1].Status {
+			t.Errorf("the order %d is %s instead of %s", o.ID, o.Status, f.Orders[o.ID-1].Status)
+		}
+	}
+
+	// Seeding twice would give other IDs to the records
+	if _, err := seed.Apply(context.Background(), f, target); err != seed.ErrNotEmpty {
+		t.Errorf("expected the second seed to be refused, got %v", err)
+	}
+}
+
+// Test that the demo fixture holds the demo accounts
+func TestApplyDemoFixture(t *testing.T) {
+	f, err := seed.Demo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := newSeedTarget()
+	if _, err := seed.Apply(context.Background(), f, target); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isUserValid(t, target.Users, "user1", "pass1") {
+		t.Error("the demo administrator can't log in")
+	}
+	categories, _ := target.Categories.All(context.Background())
+	if len(categories) != len(f.Categories) || len(categories[0].ProductIDs) == 0 {
+		t.Errorf("unexpected categories %+v", categories)
+	}
+}
+
+// Test that a fixture referring to an unknown product is rejected
+func TestInvalidFixture(t *testing.T) {
+	if _, err := seed.Parse([]byte("prodcts: []\n")); err == nil {
+		t.Error("the misspelled key should have been rejected")
+	}
+
+	f, err := seed.Parse([]byte(`
+products:
+  - name: Lamp
+    price: 10
+    quantity: 1
+orders:
+  - username: user3
+    status: paid
+    items:
+      - product: Chair
+        quantity: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seed.Apply(context.Background(), f, newSeedTarget()); err == nil {
+		t.Error("the unknown product should have been rejected")
+	}
+}
+
+// Test that a reset empties the seeded tables but keeps the users
+func TestResetSeedData(t *testing.T) {
+	db, standIn := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return &standInResult{}, nil
+	})
+	if err := seed.Reset(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	queries := standIn.received()
+	if len(queries) != 1 {
+		t.Fatalf("unexpected queries %q", queries)
+	}
+	for _, table := range []string{"articles", "products", "categories", "orders"} {
+		if !strings.Contains(queries[0], table) {
+			t.Errorf("the reset doesn't empty the %s: %q", table, queries[0])
+		}
+	}
+	if strings.Contains(queries[0], "users") {
+		t.Errorf("the reset empties the users: %q", queries[0])
+	}
+}
+
+// Helper function to create empty in-memory repositories to seed
+func newSeedTarget() seed.Target {
+	products := models.NewMemoryProductRepository()
+	return seed.Target{
+		Users:      models.NewMemoryUserRepository(),
+		Articles:   models.NewMemoryArticleRepository(),
+		Products:   products,
+		Categories: models.NewMemoryCategoryRepository(),
+		Orders:     models.NewMemoryOrderRepository(products),
+	}
+}
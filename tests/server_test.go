@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"GolangStore/routes"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test that the requests in progress are finished before shutting down
+func TestServeDrainsRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addr, done := startSlowServer(t, ctx, 200*time.Millisecond, time.Second)
+
+	responses := make(chan int, 1)
+	go func() {
+		res, err := http.Get("http://" + addr)
+		if err != nil {
+			responses <- 0
+			return
+		}
+		res.Body.Close()
+		responses <- res.StatusCode
+	}()
+
+	// Shut down while the request is in progress
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if code := <-responses; code != http.StatusOK {
+		t.Errorf("the request in progress got %d", code)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("the shutdown failed: %v", err)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("new connections are still accepted")
+	}
+}
+
+// Test that the shutdown gives up on the requests taking too long
+func TestServeShutdownDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addr, done := startSlowServer(t, ctx, time.Second, 50*time.Millisecond)
+
+	go http.Get("http://" + addr)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+// Helper function to serve requests taking the given time on a free port.
+// It returns the address of the server and the channel receiving the result
+// of routes.Serve
+func startSlowServer(t *testing.T, ctx context.Context, delay, shutdownTimeout time.Duration) (string, chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	})}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- routes.Serve(ctx, srv, ln, shutdownTimeout)
+	}()
+	return ln.Addr().String(), done
+}
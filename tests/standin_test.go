@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A stand-in for the database, answering the queries of the Postgres
+// repositories without a server. Each test registers how it answers
+type standInDB struct {
+	mu sync.Mutex
+	// The queries received, in order
+	queries []string
+	// Answer a query or a statement
+	answer func(query string, args []driver.NamedValue) (*standInResult, error)
+}
+
+// The answer to a query or a statement
+type standInResult struct {
+	columns []string
+	rows    [][]driver.Value
+	// Returned once the rows have been read, as when the connection breaks
+	// in the middle of the results
+	rowsErr      error
+	rowsAffected int64
+	// How long the database takes to answer. The query is given up when its
+	// context is done first
+	delay time.Duration
+}
+
+var (
+	standInOnce sync.Once
+	standInMu   sync.Mutex
+	standInDBs  = map[string]*standInDB{}
+)
+
+// Helper function to open a pool on a new stand-in database
+func openStandInDB(t *testing.T, answer func(query string, args []driver.NamedValue) (*standInResult, error)) (*sql.DB, *standInDB) {
+	standInOnce.Do(func() { sql.Register("standin", standInDriver{}) })
+
+	standIn := &standInDB{answer: answer}
+	standInMu.Lock()
+	standInDBs[t.Name()] = standIn
+	standInMu.Unlock()
+
+	db, err := sql.Open("standin", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		standInMu.Lock()
+		delete(standInDBs, t.Name())
+		standInMu.Unlock()
+	})
+	return db, standIn
+}
+
+// Return the queries received so far
+func (s *standInDB) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.queries...)
+}
+
+func (s *standInDB) run(ctx context.Context, query string, args []driver.NamedValue) (*standInResult, error) {
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	s.mu.Unlock()
+	res, err := s.answer(query, args)
+	if err != nil || res.delay == 0 {
+		return res, err
+	}
+
+	select {
+	case <-time.After(res.delay):
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type standInDriver struct{}
+
+func (standInDriver) Open(name string) (driver.Conn, error) {
+	standInMu.Lock()
+	defer standInMu.Unlock()
+	standIn, ok := standInDBs[name]
+	if !ok {
+		return nil, errors.New("unknown stand-in database " + name)
+	}
+	return &standInConn{db: standIn}, nil
+}
+
+type standInConn struct {
+	db *standInDB
+}
+
+func (c *standInConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("the stand-in database doesn't prepare statements")
+}
+
+func (c *standInConn) Close() error { return nil }
+
+func (c *standInConn) Begin() (driver.Tx, error) { return standInTx{}, nil }
+
+func (c *standInConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.db.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &standInRows{result: res}, nil
+}
+
+func (c *standInConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.db.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.rowsAffected), nil
+}
+
+type standInTx struct{}
+
+func (standInTx) Commit() error   { return nil }
+func (standInTx) Rollback() error { return nil }
+
+type standInRows struct {
+	result *standInResult
+	next   int
+}
+
+func (r *standInRows) Columns() []string { return r.result.columns }
+
+func (r *standInRows) Close() error { return nil }
+
+func (r *standInRows) Next(dest []driver.Value) error {
+	if r.next == len(r.result.rows) {
+		if r.result.rowsErr != nil {
+			return r.result.rowsErr
+		}
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+// Helper function to answer with the given products as rows of the
+// products table
+func productRows(products ...[]interface{}) *standInResult {
+	res := &standInResult{columns: []string{"id", "name", "description", "price", "quantity"}}
+	for _, p := range products {
+		row := make([]driver.Value, len(p))
+		for i, v := range p {
+			if n, ok := v.(int); ok {
+				v = int64(n)
+			} else if f, ok := v.(float64); ok {
+				v = []byte(strconv.FormatFloat(f, 'f', -1, 64))
+			}
+			row[i] = v
+		}
+		res.rows = append(res.rows, row)
+	}
+	return res
+}
@@ -4,6 +4,9 @@ import (
 	"GolangStore/handlers"
 	"GolangStore/middleware"
 	"GolangStore/models"
+	"context"
+	"database/sql/driver"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -11,28 +14,83 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/lib/pq"
 )
 
 /* =============================== MODELS TESTS =============================== */
 // Test the validity of different combinations of username/password
 func TestUserValidity(t *testing.T) {
-	if !models.IsUserValid("user1", "pass1") {
+	users := models.NewMemoryUserRepository(models.DemoUsers()...)
+
+	if !isUserValid(t, users, "user1", "pass1") {
 		t.Fail()
 	}
 
-	if models.IsUserValid("user2", "pass1") {
+	if isUserValid(t, users, "user2", "pass1") {
 		t.Fail()
 	}
 
-	if models.IsUserValid("user1", "") {
+	if isUserValid(t, users, "user1", "") {
 		t.Fail()
 	}
 
-	if models.IsUserValid("", "pass1") {
+	if isUserValid(t, users, "", "pass1") {
 		t.Fail()
 	}
 
-	if models.IsUserValid("User1", "pass1") {
+	if isUserValid(t, users, "User1", "pass1") {
+		t.Fail()
+	}
+}
+
+// Test that an account without a password can't be logged in to
+func TestUserWithoutPassword(t *testing.T) {
+	users := models.NewMemoryUserRepository(models.User{Username: "nopass"})
+
+	if isUserValid(t, users, "nopass", "") || isUserValid(t, users, "nopass", "pass1") {
+		t.Fail()
+	}
+}
+
+// Test that a failure of the repository isn't mistaken for wrong credentials
+func TestUserValidityRepositoryError(t *testing.T) {
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return nil, &pq.Error{Code: "08006", Message: "connection failure"}
+	})
+	users := models.NewPostgresUserRepository(db)
+
+	if valid, err := models.IsUserValid(context.Background(), users, "user1", "pass1"); valid || !errors.Is(err, models.ErrUnavailable) {
+		t.Errorf("expected the database to be unavailable, got %v", err)
+	}
+}
+
+// Test that passwords are never stored in plain text
+func TestPasswordHashing(t *testing.T) {
+	users := models.NewMemoryUserRepository()
+
+	u, err := models.RegisterNewUser(context.Background(), users, "newuser", "newpass")
+	if err != nil || u.PasswordHash == "" || strings.Contains(u.PasswordHash, "newpass") {
+		t.Fail()
+	}
+
+	// Two users with the same password don't share the same hash
+	other, err := models.RegisterNewUser(context.Background(), users, "otheruser", "newpass")
+	if err != nil || other.PasswordHash == u.PasswordHash {
+		t.Fail()
+	}
+
+	if !isUserValid(t, users, "newuser", "newpass") || isUserValid(t, users, "newuser", "wrongpass") {
+		t.Fail()
+	}
+}
+
+// Test that a stored value which isn't a bcrypt hash can't be used to log
+// in, even by sending it as the password
+func TestPlainPasswordRejected(t *testing.T) {
+	users := models.NewMemoryUserRepository(models.User{Username: "legacy", PasswordHash: "oldpass"})
+
+	if isUserValid(t, users, "legacy", "oldpass") || isUserValid(t, users, "legacy", "wrongpass") {
 		t.Fail()
 	}
 }
@@ -41,7 +99,7 @@ func TestUserValidity(t *testing.T) {
 func TestValidUserRegistration(t *testing.T) {
 	saveLists()
 
-	u, err := models.RegisterNewUser("newuser", "newpass")
+	u, err := models.RegisterNewUser(context.Background(), handlers.Users, "newuser", "newpass")
 
 	if err != nil || u.Username == "" {
 		t.Fail()
@@ -55,14 +113,14 @@ func TestInvalidUserRegistration(t *testing.T) {
 	saveLists()
 
 	// Try to register a user with a used username
-	u, err := models.RegisterNewUser("user1", "pass1")
+	u, err := models.RegisterNewUser(context.Background(), handlers.Users, "user1", "pass1")
 
 	if err == nil || u != nil {
 		t.Fail()
 	}
 
 	// Try to register with a blank password
-	u, err = models.RegisterNewUser("newuser", "")
+	u, err = models.RegisterNewUser(context.Background(), handlers.Users, "newuser", "")
 
 	if err == nil || u != nil {
 		t.Fail()
@@ -76,26 +134,41 @@ func TestUsernameAvailability(t *testing.T) {
 	saveLists()
 
 	// This username should be available
-	if !models.IsUsernameAvailable("newuser") {
+	if !isUsernameAvailable(t, handlers.Users, "newuser") {
 		t.Fail()
 	}
 
 	// This username should not be available
-	if models.IsUsernameAvailable("user1") {
+	if isUsernameAvailable(t, handlers.Users, "user1") {
 		t.Fail()
 	}
 
 	// Register a new user
-	models.RegisterNewUser("newuser", "newpass")
+	models.RegisterNewUser(context.Background(), handlers.Users, "newuser", "newpass")
 
 	// This newly registered username should not be available
-	if models.IsUsernameAvailable("newuser") {
+	if isUsernameAvailable(t, handlers.Users, "newuser") {
 		t.Fail()
 	}
 
 	restoreLists()
 }
 
+// Test that a repository failure isn't mistaken for a taken username
+func TestUsernameAvailabilityRepositoryError(t *testing.T) {
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return nil, &pq.Error{Code: "08006", Message: "connection failure"}
+	})
+	users := models.NewPostgresUserRepository(db)
+
+	if available, err := models.IsUsernameAvailable(context.Background(), users, "newuser"); available || !errors.Is(err, models.ErrUnavailable) {
+		t.Errorf("expected the database to be unavailable, got %v", err)
+	}
+	if _, err := models.RegisterNewUser(context.Background(), users, "newuser", "newpass"); !errors.Is(err, models.ErrUnavailable) {
+		t.Errorf("expected the database to be unavailable, got %v", err)
+	}
+}
+
 /* =============================== HANDLERS TESTS =============================== */
 
 // Test that a GET request to the login page returns
@@ -108,7 +181,7 @@ func TestShowLoginPageAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
 	r.GET("/u/login", middleware.EnsureNotLoggedIn(), handlers.ShowLoginPage)
@@ -161,7 +234,7 @@ func TestLoginAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
 	r.POST("/u/login", middleware.EnsureNotLoggedIn(), handlers.PerformLogin)
@@ -256,7 +329,7 @@ func TestShowRegistrationPageAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
 	r.GET("/u/register", middleware.EnsureNotLoggedIn(), handlers.ShowRegistrationPage)
@@ -309,7 +382,7 @@ func TestRegisterAuthenticated(t *testing.T) {
 	r := getRouter(true)
 
 	// Set the token cookie to simulate an authenticated user
-	http.SetCookie(w, &http.Cookie{Name: "token", Value: "123"})
+	http.SetCookie(w, getSessionCookie(t))
 
 	// Define the route similar to its definition in the routes file
 	r.POST("/u/register", middleware.EnsureNotLoggedIn(), handlers.Register)
@@ -394,6 +467,73 @@ func TestRegisterUnauthenticatedUnavailableUsername(t *testing.T) {
 	}
 }
 
+// Test that a registration failing on the server's side doesn't show the
+// error to the user
+func TestRegisterServerError(t *testing.T) {
+	saveLists()
+	defer restoreLists()
+	db, _ := openStandInDB(t, func(query string, args []driver.NamedValue) (*standInResult, error) {
+		return nil, &pq.Error{Code: "08006", Message: "connection failure"}
+	})
+	handlers.Users = models.NewPostgresUserRepository(db)
+
+	r := getRouter(true)
+	r.POST("/u/register", middleware.EnsureNotLoggedIn(), handlers.Register)
+	registrationPayload := getRegistrationPOSTPayload()
+	req, _ := http.NewRequest("POST", "/u/register", strings.NewReader(registrationPayload))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusServiceUnavailable && !strings.Contains(w.Body.String(), "connection failure")
+	})
+}
+
+// Test that a successful login hands out the token of a stored session
+func TestLoginStartsSession(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.POST("/u/login", middleware.EnsureNotLoggedIn(), handlers.PerformLogin)
+
+	// Create a request to send to the above route
+	loginPayload := getLoginPOSTPayload()
+	req, _ := http.NewRequest("POST", "/u/login", strings.NewReader(loginPayload))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(loginPayload)))
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == "token" {
+				session, err := handlers.Sessions.Get(context.Background(), cookie.Value)
+				return err == nil && session.Username == "user1"
+			}
+		}
+		return false
+	})
+}
+
+// Test that a GET request to the logout route ends the session
+func TestLogoutEndsSession(t *testing.T) {
+	r := getRouter(true)
+
+	// Define the route similar to its definition in the routes file
+	r.GET("/u/logout", middleware.EnsureLoggedIn(), handlers.Logout)
+
+	// Create a request to send to the above route
+	cookie := getSessionCookie(t)
+	req, _ := http.NewRequest("GET", "/u/logout", nil)
+	req.AddCookie(cookie)
+
+	testHTTPResponse(t, r, req, func(w *httptest.ResponseRecorder) bool {
+		return w.Code == http.StatusTemporaryRedirect
+	})
+
+	// The token can't be used anymore
+	if _, err := handlers.Sessions.Get(context.Background(), cookie.Value); err != models.ErrSessionNotFound {
+		t.Fail()
+	}
+}
+
 func getLoginPOSTPayload() string {
 	params := url.Values{}
 	params.Add("username", "user1")
@@ -409,3 +549,23 @@ func getRegistrationPOSTPayload() string {
 
 	return params.Encode()
 }
+
+// Helper function to check credentials, failing the test when the users
+// can't be read
+func isUserValid(t *testing.T, users models.UserRepository, username, password string) bool {
+	valid, err := models.IsUserValid(context.Background(), users, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return valid
+}
+
+// Helper function to check that a username is available, failing the test
+// when the users can't be read
+func isUsernameAvailable(t *testing.T, users models.UserRepository, username string) bool {
+	available, err := models.IsUsernameAvailable(context.Background(), users, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return available
+}